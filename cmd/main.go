@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/xml"
 	"flag"
 	"fmt"
@@ -82,8 +83,25 @@ func main() {
 		return
 	}
 
-	var d wsdl.Definitions
-	unmarshal(*wsdlFile, &d)
+	// imports are resolved relative to the WSDL file
+	d, err := wsdl.ReadFile(context.Background(), *wsdlFile, wsdl.SplitFetcher{
+		Local:  wsdl.FileFetcher{},
+		Remote: wsdl.HTTPFetcher{},
+	})
+	if err != nil {
+		exit(err)
+	}
+
+	// the code is generated for the first port of the first service and the
+	// first port type
+	switch {
+	case len(d.Services) == 0:
+		exit(fmt.Errorf("no service in '%s'", *wsdlFile))
+	case len(d.Services[0].Ports) == 0:
+		exit(fmt.Errorf("no port in service '%s' of '%s'", d.Services[0].Name, *wsdlFile))
+	case len(d.PortTypes) == 0:
+		exit(fmt.Errorf("no port type in '%s'", *wsdlFile))
+	}
 
 	var s xsd.Schema
 
@@ -94,7 +112,10 @@ func main() {
 		unmarshal(*xsdFile, &s)
 	} else {
 		// TODO: na verdade podemos ter mais de um schema
-		s = d.Types.Schemas[0]
+		if len(d.Types.Schemata) == 0 {
+			exit(fmt.Errorf("no schema in '%s'", *wsdlFile))
+		}
+		s = d.Types.Schemata[0]
 	}
 
 	buf, f := createOut(*outFile)
@@ -102,7 +123,7 @@ func main() {
 	defer f.Close()
 
 	// create de service file
-	create(&d, &s, buf, f)
+	create(d, &s, buf, f)
 }
 
 func unmarshal(n string, i interface{}) {
@@ -163,8 +184,9 @@ func create(d *wsdl.Definitions, s *xsd.Schema, b *bufio.Writer, file *os.File)
 	}
 
 	data.PackageName = *packageName
-	data.ServiceName = d.Service.Name
-	data.ServiceUrl = d.Service.Port.Address.Location
+	// the first port of the first service
+	data.ServiceName = d.Services[0].Name
+	data.ServiceUrl = d.Services[0].Ports[0].Address.Location
 	data.Methods = make([]Method, 0)
 	data.Messages = make([]Message, 0)
 
@@ -184,39 +206,35 @@ func create(d *wsdl.Definitions, s *xsd.Schema, b *bufio.Writer, file *os.File)
 				Fields: make([]Field, 0),
 			}
 
+			var elements []xsd.Element
 			if s.ComplexTypes[i].Content == nil {
-				for ii := 0; ii < len(s.ComplexTypes[i].Sequence); ii++ {
-					fi := Field{
-						Name:    exportableSymbol(s.ComplexTypes[i].Sequence[ii].Name),
-						Type:    decodeType(s.ComplexTypes[i].Sequence[ii]),
-						XMLName: s.ComplexTypes[i].Sequence[ii].Name,
-					}
-					t.Fields = append(t.Fields, fi)
-				}
+				elements = s.ComplexTypes[i].Elements()
 			} else {
 				t.Fields = append(t.Fields, Field{Name: exportableSymbol(s.ComplexTypes[i].Content.Extension.Base[4:])})
-				for ii := 0; ii < len(s.ComplexTypes[i].Content.Extension.Sequence); ii++ {
-					fi := Field{
-						Name:    exportableSymbol(s.ComplexTypes[i].Content.Extension.Sequence[ii].Name),
-						Type:    decodeType(s.ComplexTypes[i].Content.Extension.Sequence[ii]),
-						XMLName: s.ComplexTypes[i].Content.Extension.Sequence[ii].Name,
-					}
-					t.Fields = append(t.Fields, fi)
+				elements = s.ComplexTypes[i].Content.Extension.Elements()
+			}
+
+			for _, e := range elements {
+				fi := Field{
+					Name:    exportableSymbol(e.Name),
+					Type:    decodeType(e),
+					XMLName: e.Name,
 				}
+				t.Fields = append(t.Fields, fi)
 			}
 			data.Types = append(data.Types, t)
 		}
 	}
 
-	for i := 0; i < len(d.PortType.Operations); i++ {
+	for i := 0; i < len(d.PortTypes[0].Operations); i++ {
 
 		m := Method{}
-		m.Name = exportableSymbol(d.PortType.Operations[i].Name)
+		m.Name = exportableSymbol(d.PortTypes[0].Operations[i].Name)
 		// TODO: get correct action in binding area
 		m.Action = ""
 
 		// find input parameter type
-		e := findElement(s, d.PortType.Operations[i].Input.Message)
+		e := findElement(s, d.PortTypes[0].Operations[i].Input.Message)
 
 		var c *xsd.ComplexType
 
@@ -235,9 +253,10 @@ func create(d *wsdl.Definitions, s *xsd.Schema, b *bufio.Writer, file *os.File)
 			message.XMLName = e.Name
 		}
 
-		if len(c.Sequence) > 0 {
-			si := strings.Index(c.Sequence[0].Type, ":")
-			for _, v := range c.Sequence {
+		sequence := c.Elements()
+		if len(sequence) > 0 {
+			si := strings.Index(sequence[0].Type, ":")
+			for _, v := range sequence {
 				messageParam := MessageParamIn{}
 				messageParam.ParamName = exportableSymbol(v.Name)
 				messageParam.XMLParamName = v.Name
@@ -248,7 +267,7 @@ func create(d *wsdl.Definitions, s *xsd.Schema, b *bufio.Writer, file *os.File)
 			}
 			data.Messages = append(data.Messages, message)
 
-			m.InputType = exportableSymbol(c.Sequence[0].Type[si+1:])
+			m.InputType = exportableSymbol(sequence[0].Type[si+1:])
 			m.MessageIn = message.Name
 			m.ParamInName = message.ParamName
 			m.HasParams = true
@@ -257,7 +276,7 @@ func create(d *wsdl.Definitions, s *xsd.Schema, b *bufio.Writer, file *os.File)
 		}
 
 		// find output parameter type
-		e = findElement(s, d.PortType.Operations[i].Output.Message)
+		e = findElement(s, d.PortTypes[0].Operations[i].Output.Message)
 
 		if e.ComplexTypes == nil {
 			c = findComplexType(s, e.Name)
@@ -265,7 +284,8 @@ func create(d *wsdl.Definitions, s *xsd.Schema, b *bufio.Writer, file *os.File)
 			c = e.ComplexTypes
 		}
 
-		si := strings.Index(c.Sequence[0].Type, ":")
+		sequence = c.Elements()
+		si := strings.Index(sequence[0].Type, ":")
 
 		message = Message{}
 
@@ -280,7 +300,7 @@ func create(d *wsdl.Definitions, s *xsd.Schema, b *bufio.Writer, file *os.File)
 			message.XMLName = e.Name
 		}
 
-		for _, v := range c.Sequence {
+		for _, v := range sequence {
 			messageParam := MessageParamIn{}
 			messageParam.ParamName = exportableSymbol(v.Name)
 			messageParam.XMLParamName = v.Name
@@ -290,16 +310,16 @@ func create(d *wsdl.Definitions, s *xsd.Schema, b *bufio.Writer, file *os.File)
 			message.Params = append(message.Params, messageParam)
 		}
 
-		/*message.ParamName = exportableSymbol(c.Sequence[0].Name)
-		message.XMLParamName = c.Sequence[0].Name
-		message.ParamType = exportableSymbol(c.Sequence[0].Type[si+1:])
+		/*message.ParamName = exportableSymbol(sequence[0].Name)
+		message.XMLParamName = sequence[0].Name
+		message.ParamType = exportableSymbol(sequence[0].Type[si+1:])
 		message.Input = false*/
 
 		data.Messages = append(data.Messages, message)
 
-		m.OutputType = exportableSymbol(c.Sequence[0].Type[si+1:])
+		m.OutputType = exportableSymbol(sequence[0].Type[si+1:])
 		m.MessageOut = message.Name
-		m.ParamOutName = exportableSymbol(c.Sequence[0].Name)
+		m.ParamOutName = exportableSymbol(sequence[0].Name)
 
 		data.Methods = append(data.Methods, m)
 	}
@@ -0,0 +1,44 @@
+package goat
+
+import (
+	"context"
+	"io"
+)
+
+type endpointKey struct{}
+
+// WithEndpoint returns a copy of ctx which makes requests sent with it go to
+// endpoint instead of the address of their service.
+func WithEndpoint(ctx context.Context, endpoint string) context.Context {
+	return context.WithValue(ctx, endpointKey{}, endpoint)
+}
+
+// ctxReader fails reads once its context is done, so that decoding stops
+// in the middle of a response.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (self ctxReader) Read(p []byte) (n int, err error) {
+	if err = self.ctx.Err(); err != nil {
+		return
+	}
+
+	return self.r.Read(p)
+}
+
+// ctxWriter fails writes once its context is done, so that encoding stops
+// in the middle of a request.
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (self ctxWriter) Write(p []byte) (n int, err error) {
+	if err = self.ctx.Err(); err != nil {
+		return
+	}
+
+	return self.w.Write(p)
+}
@@ -0,0 +1,171 @@
+package goat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/justwatchcom/goat/wsdl"
+)
+
+// Fault is a SOAP fault returned by a webservice. It is filled from SOAP 1.1
+// faults (faultcode, faultstring, faultactor, detail) as well as from SOAP 1.2
+// faults (Code, Subcode, Reason, Node, Role, Detail).
+type Fault struct {
+	Code     string
+	Subcodes []string
+	String   string
+	Actor    string
+	Node     string
+
+	// Detail holds the raw content of the fault's detail element and
+	// DetailName the name of its first child element.
+	Detail     []byte
+	DetailName xml.Name
+
+	// Name is the name of the fault declared by the operation whose message
+	// matches the detail. It is empty if the operation declares no such fault.
+	Name string
+}
+
+type rawFault struct {
+	// SOAP 1.1
+	FaultCode   string    `xml:"faultcode"`
+	FaultString string    `xml:"faultstring"`
+	FaultActor  string    `xml:"faultactor"`
+	FaultDetail rawDetail `xml:"detail"`
+
+	// SOAP 1.2
+	Code   rawCode `xml:"Code"`
+	Reason struct {
+		Text []string `xml:"Text"`
+	} `xml:"Reason"`
+	Node   string    `xml:"Node"`
+	Role   string    `xml:"Role"`
+	Detail rawDetail `xml:"Detail"`
+}
+
+type rawCode struct {
+	Value   string   `xml:"Value"`
+	Subcode *rawCode `xml:"Subcode"`
+}
+
+type rawDetail struct {
+	Data     []byte `xml:",innerxml"`
+	Elements []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+func (self *Fault) Error() string {
+	code := self.Code
+	if len(self.Subcodes) > 0 {
+		code = fmt.Sprintf("%s (%s)", code, strings.Join(self.Subcodes, ", "))
+	}
+
+	if self.Name != "" {
+		return fmt.Sprintf("soap fault '%s': %s: %s", self.Name, code, self.String)
+	}
+	return fmt.Sprintf("soap fault: %s: %s", code, self.String)
+}
+
+// DecodeDetail unmarshals the first element of the fault's detail into v,
+// which is typically a struct modelling the fault named by Name.
+func (self *Fault) DecodeDetail(v interface{}) (err error) {
+	if len(self.Detail) == 0 {
+		err = fmt.Errorf("soap fault has no detail")
+		return
+	}
+
+	err = xml.Unmarshal(self.Detail, v)
+	return
+}
+
+// parseFault returns the fault contained in the body of the soap envelope
+// data or nil if the body does not hold a fault. Faults are Fault elements
+// of the SOAP 1.1 or 1.2 envelope namespace, not those of an application.
+func parseFault(data []byte) (f *Fault, err error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var start *xml.StartElement
+	start, err = bodyElement(d)
+	if err != nil || start == nil {
+		return
+	}
+
+	if !isEnvelopeNamespace(start.Name.Space) || start.Name.Local != "Fault" {
+		return
+	}
+
+	raw := new(rawFault)
+	err = d.DecodeElement(raw, start)
+	if err != nil {
+		return
+	}
+
+	f = &Fault{
+		Code:   strings.TrimSpace(raw.FaultCode),
+		String: strings.TrimSpace(raw.FaultString),
+		Actor:  strings.TrimSpace(raw.FaultActor),
+		Detail: raw.FaultDetail.Data,
+	}
+	detail := raw.FaultDetail
+
+	if f.Code == "" {
+		f.Code = strings.TrimSpace(raw.Code.Value)
+		for c := raw.Code.Subcode; c != nil; c = c.Subcode {
+			f.Subcodes = append(f.Subcodes, strings.TrimSpace(c.Value))
+		}
+
+		if len(raw.Reason.Text) > 0 {
+			f.String = strings.TrimSpace(raw.Reason.Text[0])
+		}
+		f.Actor = strings.TrimSpace(raw.Role)
+		f.Node = strings.TrimSpace(raw.Node)
+		f.Detail = raw.Detail.Data
+		detail = raw.Detail
+	}
+
+	// the name is taken while decoding, which resolves the prefixes the
+	// envelope declares
+	if len(detail.Elements) > 0 {
+		f.DetailName = detail.Elements[0].XMLName
+	}
+	return
+}
+
+// bodyElement reads the envelope from d up to the first element of its body
+// and returns it, or nil if the body is empty or missing.
+func bodyElement(d *xml.Decoder) (start *xml.StartElement, err error) {
+	var body bool
+	for start == nil {
+		var t xml.Token
+		t, err = d.Token()
+		if err == io.EOF {
+			err = nil
+			return
+		} else if err != nil {
+			return
+		}
+
+		switch se := t.(type) {
+		case xml.StartElement:
+			if body {
+				start = &se
+			} else if isEnvelopeNamespace(se.Name.Space) && se.Name.Local == "Body" {
+				body = true
+			}
+		case xml.EndElement:
+			if body {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func isEnvelopeNamespace(space string) bool {
+	return space == wsdl.SoapEnvelopeNamespace || space == wsdl.Soap12EnvelopeNamespace
+}
@@ -0,0 +1,154 @@
+package goat
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/justwatchcom/goat/wsdl"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type faultTestCase struct {
+	comment        string
+	body           string
+	isFault        bool
+	expectedFault  Fault
+	expectedDetail string
+}
+
+var (
+	faultTestCases = []faultTestCase{
+		{
+			comment: "no fault",
+			body:    `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><getResponse><rval/></getResponse></soap:Body></soap:Envelope>`,
+		},
+		{
+			comment: "fault of the application",
+			body:    `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><Fault xmlns="urn:service"><faultcode>soap:Server</faultcode></Fault></soap:Body></soap:Envelope>`,
+		},
+		{
+			comment: "soap 1.1 fault",
+			body: `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault>
+  <faultcode>soap:Server</faultcode>
+  <faultstring>[QuotaCheckError.INVALID_TOKEN_HEADER @ ]</faultstring>
+  <detail><ApiExceptionFault xmlns="https://adwords.google.com/api/adwords/cm/v201509"><message>invalid token</message></ApiExceptionFault></detail>
+</soap:Fault></soap:Body></soap:Envelope>`,
+			isFault: true,
+			expectedFault: Fault{
+				Code:   "soap:Server",
+				String: "[QuotaCheckError.INVALID_TOKEN_HEADER @ ]",
+				DetailName: xml.Name{
+					Space: "https://adwords.google.com/api/adwords/cm/v201509",
+					Local: "ApiExceptionFault",
+				},
+			},
+			expectedDetail: "invalid token",
+		},
+		{
+			comment: "soap 1.2 fault",
+			body: `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:m="urn:timeouts"><env:Body><env:Fault>
+  <env:Code><env:Value>env:Sender</env:Value><env:Subcode><env:Value>m:MessageTimeout</env:Value></env:Subcode></env:Code>
+  <env:Reason><env:Text xml:lang="en">Sender Timeout</env:Text></env:Reason>
+  <env:Role>http://example.org/role</env:Role>
+  <env:Detail><m:MaxTime><message>P5M</message></m:MaxTime></env:Detail>
+</env:Fault></env:Body></env:Envelope>`,
+			isFault: true,
+			expectedFault: Fault{
+				Code:       "env:Sender",
+				Subcodes:   []string{"m:MessageTimeout"},
+				String:     "Sender Timeout",
+				Actor:      "http://example.org/role",
+				DetailName: xml.Name{Space: "urn:timeouts", Local: "MaxTime"},
+			},
+			expectedDetail: "P5M",
+		},
+	}
+)
+
+func TestParseFault(t *testing.T) {
+	Convey("given a soap envelope", t, func() {
+		for _, c := range faultTestCases {
+			Convey(fmt.Sprintf("test case '%s'", c.comment), func() {
+				f, err := parseFault([]byte(c.body))
+				So(err, ShouldBeNil)
+				if !c.isFault {
+					So(f, ShouldBeNil)
+					return
+				}
+
+				So(f, ShouldNotBeNil)
+				So(f.Code, ShouldEqual, c.expectedFault.Code)
+				So(f.Subcodes, ShouldResemble, c.expectedFault.Subcodes)
+				So(f.String, ShouldEqual, c.expectedFault.String)
+				So(f.Actor, ShouldEqual, c.expectedFault.Actor)
+				So(f.DetailName, ShouldResemble, c.expectedFault.DetailName)
+
+				detail := struct {
+					Message string `xml:"message"`
+				}{}
+				err = f.DecodeDetail(&detail)
+				So(err, ShouldBeNil)
+				So(detail.Message, ShouldEqual, c.expectedDetail)
+			})
+		}
+	})
+}
+
+type faultNameTestCase struct {
+	comment      string
+	detail       string
+	expectedName string
+}
+
+var (
+	faultNameTestCases = []faultNameTestCase{
+		{
+			comment:      "declared fault",
+			detail:       `<ApiException xmlns="urn:service"><message>invalid token</message></ApiException>`,
+			expectedName: "ApiExceptionFault",
+		},
+		{
+			comment: "fault of another namespace",
+			detail:  `<ApiException xmlns="urn:other"><message>invalid token</message></ApiException>`,
+		},
+	}
+)
+
+func TestWebservice_Fault(t *testing.T) {
+	Convey("given a service declaring a fault", t, func() {
+		s := new(wsdl.Definitions)
+		err := xml.Unmarshal([]byte(serviceWSDL), s)
+		So(err, ShouldBeNil)
+		So(s.PortTypes[0].Operations[0].Fault.Name, ShouldEqual, "ApiExceptionFault")
+		So(s.Binding[0].Operations[0].Fault.Name, ShouldEqual, "ApiExceptionFault")
+
+		for _, c := range faultNameTestCases {
+			Convey(fmt.Sprintf("test case '%s'", c.comment), func() {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusInternalServerError)
+					fmt.Fprintf(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault>
+  <faultcode>soap:Server</faultcode>
+  <faultstring>invalid token</faultstring>
+  <detail>%s</detail>
+</soap:Fault></soap:Body></soap:Envelope>`, c.detail)
+				}))
+				defer server.Close()
+
+				ws := NewWebservice(nil, nil)
+				ws.services["Service"] = s
+
+				var res map[string]interface{}
+				err := ws.DoContext(WithEndpoint(context.Background(), server.URL), "Service", "get", &res, map[string]interface{}{"get/id": 1})
+				f, ok := err.(*Fault)
+				So(ok, ShouldBeTrue)
+				So(f.String, ShouldEqual, "invalid token")
+				So(f.Name, ShouldEqual, c.expectedName)
+			})
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package goat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// Logger receives the messages and the request/response exchanges of a
+// Webservice.
+type Logger interface {
+	Printf(format string, v ...interface{})
+	LogExchange(e *Exchange)
+}
+
+// Exchange is a single request sent by a Webservice along with its
+// response. Header fields listed in Webservice.Redact are already
+// redacted in Request and Response.
+type Exchange struct {
+	Service   string
+	Operation string
+	Request   []byte
+	Response  []byte
+	Start     time.Time
+	Duration  time.Duration
+	Err       error
+}
+
+// RedactedValue replaces the values of redacted header fields.
+const RedactedValue = "REDACTED"
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+func (nopLogger) LogExchange(e *Exchange)                {}
+
+type writerLogger struct {
+	*log.Logger
+}
+
+// NewLogger returns a Logger writing messages as well as the raw request and
+// response envelopes to w.
+func NewLogger(w io.Writer) Logger {
+	return writerLogger{log.New(w, "", log.LstdFlags)}
+}
+
+func (self writerLogger) LogExchange(e *Exchange) {
+	self.Printf("%s/%s took %s (error: %v)\nrequest:\n%s\nresponse:\n%s", e.Service, e.Operation, e.Duration, e.Err, e.Request, e.Response)
+}
+
+func (self *Webservice) logger() Logger {
+	if self.Logger == nil {
+		return nopLogger{}
+	}
+	return self.Logger
+}
+
+func (self *Webservice) logExchange(e *Exchange) {
+	if len(self.Redact) > 0 {
+		e.Request = redact(e.Request, self.Redact)
+		e.Response = redact(e.Response, self.Redact)
+	}
+
+	self.logger().LogExchange(e)
+}
+
+// redact replaces the character data of the envelope's header fields whose
+// path (like "RequestHeader/developerToken") is in paths.
+func redact(envelope []byte, paths []string) []byte {
+	d := xml.NewDecoder(bytes.NewReader(envelope))
+	buf := new(bytes.Buffer)
+
+	var stack []string
+	var last int64
+	for {
+		offset := d.InputOffset()
+		t, err := d.RawToken()
+		if err != nil {
+			break
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) < 3 || stack[1] != "Header" || len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+
+			path := strings.Join(stack[2:], "/")
+			for _, p := range paths {
+				if p == path {
+					buf.Write(envelope[last:offset])
+					buf.WriteString(RedactedValue)
+					last = d.InputOffset()
+					break
+				}
+			}
+		}
+	}
+
+	buf.Write(envelope[last:])
+	return buf.Bytes()
+}
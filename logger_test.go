@@ -0,0 +1,48 @@
+package goat
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type redactTestCase struct {
+	comment        string
+	envelope       string
+	paths          []string
+	expectedResult string
+}
+
+var (
+	redactTestCases = []redactTestCase{
+		{
+			comment:        "redact header field",
+			envelope:       `<s:Envelope><s:Header><RequestHeader><developerToken>secret</developerToken><userAgent>goat</userAgent></RequestHeader></s:Header></s:Envelope>`,
+			paths:          []string{"RequestHeader/developerToken"},
+			expectedResult: `<s:Envelope><s:Header><RequestHeader><developerToken>REDACTED</developerToken><userAgent>goat</userAgent></RequestHeader></s:Header></s:Envelope>`,
+		},
+		{
+			comment:        "keep body fields with the same path",
+			envelope:       `<s:Envelope><s:Body><RequestHeader><developerToken>secret</developerToken></RequestHeader></s:Body></s:Envelope>`,
+			paths:          []string{"RequestHeader/developerToken"},
+			expectedResult: `<s:Envelope><s:Body><RequestHeader><developerToken>secret</developerToken></RequestHeader></s:Body></s:Envelope>`,
+		},
+		{
+			comment:        "keep malformed envelopes",
+			envelope:       `internal server error`,
+			paths:          []string{"RequestHeader/developerToken"},
+			expectedResult: `internal server error`,
+		},
+	}
+)
+
+func TestRedact(t *testing.T) {
+	Convey("given an envelope", t, func() {
+		for _, c := range redactTestCases {
+			Convey(fmt.Sprintf("test case '%s'", c.comment), func() {
+				So(string(redact([]byte(c.envelope), c.paths)), ShouldEqual, c.expectedResult)
+			})
+		}
+	})
+}
@@ -2,13 +2,17 @@ package goat
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
+	"time"
+
+	"github.com/justwatchcom/goat/wsdl"
+	"github.com/justwatchcom/goat/xsd"
 )
 
 type ResponseEnvelope struct {
@@ -23,59 +27,250 @@ type ResponseEnvelope struct {
 	}
 }
 
-func (self *Webservice) NewRequest(service, method string, params map[string]interface{}, buf io.Writer) (err error) {
-	s := self.services[service]
-	if s == nil {
-		err = fmt.Errorf("no such service '%s'", service)
+func (self *Webservice) NewRequest(service, method string, params interface{}, buf io.Writer) (err error) {
+	return self.NewRequestContext(context.Background(), service, method, params, buf)
+}
+
+// NewRequestContext writes the request envelope of method to buf. The params
+// are either keyed by paths like "get/serviceSelector/fields" or a value like
+// a struct with xml tags holding the content of the body element. Encoding
+// stops with the context's error once ctx is done.
+func (self *Webservice) NewRequestContext(ctx context.Context, service, method string, params interface{}, buf io.Writer) (err error) {
+	var s *wsdl.Definitions
+	var operation string
+	s, operation, err = self.service(service, method)
+	if err != nil {
 		return
 	}
 
-	err = s.WriteRequest(method, buf, self.header, params)
+	err = ctx.Err()
+	if err != nil {
+		return
+	}
+
+	err = s.WriteRequest(operation, ctxWriter{ctx, buf}, self.header, params)
 	return
 }
 
+// SendBuffer posts the request envelope in buf like SendBufferMethod, taking
+// the method from the element in the body of the envelope.
 func (self *Webservice) SendBuffer(service string, res interface{}, buf io.Reader) (err error) {
-	s := self.services[service]
-	if s == nil {
-		err = fmt.Errorf("no such service '%s'", service)
+	var s *wsdl.Definitions
+	s, _, err = self.service(service, "")
+	if err != nil {
 		return
 	}
 
-	var resp *http.Response
-	resp, err = self.Client.Post(s.Service.Port.Address.Location, "application/soap+xml", buf)
+	var b []byte
+	b, err = ioutil.ReadAll(buf)
 	if err != nil {
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		var b []byte
-		b, err = ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return
+	var start *xml.StartElement
+	start, err = bodyElement(xml.NewDecoder(bytes.NewReader(b)))
+	if err != nil {
+		return
+	}
+
+	if start == nil {
+		err = errors.New("request envelope has no body element")
+		return
+	}
+
+	var method string
+	method, err = s.OperationOf(start.Name)
+	if err != nil {
+		return
+	}
+
+	err = self.SendBufferMethod(service, method, res, bytes.NewReader(b))
+	return
+}
+
+// SendBufferMethod posts the request envelope in buf for the operation method
+// of service and decodes the response into res, see SendBufferContext.
+func (self *Webservice) SendBufferMethod(service, method string, res interface{}, buf io.Reader) (err error) {
+	return self.SendBufferContext(context.Background(), service, method, res, buf)
+}
+
+// SendBufferContext posts the request envelope in buf through the client of
+// the webservice and decodes the response into res. For a res of type
+// *map[string]interface{} or *interface{}, the response is decoded against
+// the schema, see wsdl.Definitions.ReadResponse. The request is canceled and
+// decoding stops with the context's error once ctx is done.
+func (self *Webservice) SendBufferContext(ctx context.Context, service, method string, res interface{}, buf io.Reader) (err error) {
+	return self.SendBufferHeaderContext(ctx, service, method, nil, res, buf)
+}
+
+// SendBufferHeaderContext is like SendBufferContext, but also decodes the
+// SOAP header of the response into header unless it is nil. A header of type
+// *map[string]interface{} or *interface{} gets the content of the header
+// element of the operation's output like "ResponseHeader", see
+// wsdl.Definitions.ReadResponseHeader, while other values are decoded from
+// the header element like the body.
+func (self *Webservice) SendBufferHeaderContext(ctx context.Context, service, method string, header, res interface{}, buf io.Reader) (err error) {
+	var s *wsdl.Definitions
+	var operation string
+	s, operation, err = self.service(service, method)
+	if err != nil {
+		return
+	}
+
+	var endpoint string
+	endpoint, err = self.endpoint(ctx, s, service, operation)
+	if err != nil {
+		return
+	}
+
+	var reqData, b []byte
+	reqData, err = ioutil.ReadAll(buf)
+	if err != nil {
+		return
+	}
+
+	start := time.Now()
+	defer func() {
+		self.logExchange(&Exchange{
+			Service:   service,
+			Operation: method,
+			Request:   reqData,
+			Response:  b,
+			Start:     start,
+			Duration:  time.Since(start),
+			Err:       err,
+		})
+	}()
+
+	var req *http.Request
+	req, err = http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(reqData))
+	if err != nil {
+		return
+	}
+
+	req.Header, err = s.Header(operation)
+	if err != nil {
+		return
+	}
+
+	var resp *http.Response
+	resp, err = self.Client.Do(req)
+	if err != nil {
+		// report the context's error rather than the one of the client,
+		// which wraps it
+		if ctx.Err() != nil {
+			err = ctx.Err()
 		}
+		return
+	}
+	defer resp.Body.Close()
 
-		err = errors.New(string(b))
+	b, err = ioutil.ReadAll(ctxReader{ctx, resp.Body})
+	if err != nil {
 		return
 	}
 
 	e := new(ResponseEnvelope)
-	err = xml.NewDecoder(io.TeeReader(resp.Body, os.Stdout)).Decode(e)
+	err = xml.Unmarshal(b, e)
 	if err != nil {
+		if resp.StatusCode != http.StatusOK {
+			err = errors.New(string(b))
+		}
+		return
+	}
+
+	var f *Fault
+	f, err = parseFault(b)
+	if err != nil {
+		return
+	}
+
+	if f != nil {
+		if f.DetailName.Local != "" {
+			f.Name, _ = s.GetFault(operation, f.DetailName)
+		}
+
+		err = f
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = errors.New(string(b))
 		return
 	}
 
-	err = xml.Unmarshal(e.Body.Data, res)
+	if header != nil {
+		err = decode(ctxReader{ctx, bytes.NewReader(e.Header.Data)}, header, func(r io.Reader) (interface{}, error) {
+			return s.ReadResponseHeader(operation, r)
+		})
+		if err != nil {
+			err = fmt.Errorf("response header of '%s': %s", method, err)
+			return
+		}
+	}
+
+	err = decode(ctxReader{ctx, bytes.NewReader(e.Body.Data)}, res, func(r io.Reader) (interface{}, error) {
+		return s.ReadResponse(operation, r)
+	})
 	return
 }
 
-func (self *Webservice) Do(service, method string, res interface{}, params map[string]interface{}) (err error) {
+// decode decodes r into v, reading *map[string]interface{} and *interface{}
+// values against the schema with read.
+func decode(r io.Reader, v interface{}, read func(io.Reader) (interface{}, error)) (err error) {
+	switch p := v.(type) {
+	case *map[string]interface{}:
+		var dv interface{}
+		dv, err = read(r)
+		if err != nil {
+			return
+		}
+
+		if dv == nil {
+			*p = nil
+			return
+		}
+
+		m, ok := dv.(map[string]interface{})
+		if !ok {
+			err = fmt.Errorf("have %T, not a map", dv)
+			return
+		}
+		*p = m
+	case *interface{}:
+		*p, err = read(r)
+	default:
+		err = xsd.NewDecoder(r).Decode(v)
+	}
+
+	return
+}
+
+func (self *Webservice) Do(service, method string, res, params interface{}) (err error) {
+	return self.DoContext(context.Background(), service, method, res, params)
+}
+
+// DoContext encodes a request of method from params, sends it and decodes
+// the response into res, honouring the cancellation and deadline of ctx.
+func (self *Webservice) DoContext(ctx context.Context, service, method string, res, params interface{}) (err error) {
+	return self.DoHeaderContext(ctx, service, method, nil, res, params)
+}
+
+// DoHeader is like Do, but also decodes the SOAP header of the response into
+// header, see DoHeaderContext.
+func (self *Webservice) DoHeader(service, method string, header, res, params interface{}) (err error) {
+	return self.DoHeaderContext(context.Background(), service, method, header, res, params)
+}
+
+// DoHeaderContext is like DoContext, but also decodes the SOAP header of the
+// response into header, see SendBufferHeaderContext.
+func (self *Webservice) DoHeaderContext(ctx context.Context, service, method string, header, res, params interface{}) (err error) {
 	buf := new(bytes.Buffer)
-	err = self.NewRequest(service, method, params, buf)
+	err = self.NewRequestContext(ctx, service, method, params, buf)
 	if err != nil {
 		return
 	}
 
-	err = self.SendBuffer(service, res, buf)
+	err = self.SendBufferHeaderContext(ctx, service, method, header, res, buf)
 	return
 }
@@ -1,10 +1,13 @@
 package goat
 
 import (
-	"encoding/xml"
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/justwatchcom/goat/wsdl"
 )
@@ -13,6 +16,27 @@ type Webservice struct {
 	services map[string]*wsdl.Definitions
 	Client   *http.Client
 	header   map[string]interface{}
+
+	// Logger receives messages and exchanges, it is silent by default.
+	Logger Logger
+	// Redact lists header fields like "RequestHeader/developerToken" whose
+	// values are redacted before exchanges are handed to the Logger.
+	Redact []string
+	// Prefixes overrides the namespace prefixes of the WSDLs, keyed by
+	// namespace, for services added afterwards.
+	Prefixes map[string]string
+	// Strict makes services added afterwards report params which match
+	// nothing in their schemas as violations, suggesting similar names, see
+	// wsdl.Definitions.Strict.
+	Strict bool
+	// Endpoints overrides the addresses the WSDLs advertise, keyed by
+	// service like "Service" or by service and port like
+	// "Service/ServiceSoap12". WithEndpoint overrides them for a single
+	// request.
+	Endpoints map[string]string
+	// Rewrite changes the endpoint of every request to service unless it is
+	// nil, e.g. to substitute the host of the address the WSDL advertises.
+	Rewrite func(service string, endpoint *url.URL)
 }
 
 func NewWebservice(c *http.Client, header map[string]interface{}) Webservice {
@@ -24,32 +48,209 @@ func NewWebservice(c *http.Client, header map[string]interface{}) Webservice {
 		services: map[string]*wsdl.Definitions{},
 		Client:   c,
 		header:   header,
+		Logger:   nopLogger{},
 	}
 }
 
 func (self *Webservice) AddServices(urls ...string) (err error) {
+	return self.AddServicesContext(context.Background(), urls...)
+}
+
+// AddServicesContext fetches the WSDLs at urls through the client of the
+// webservice and adds their services.
+func (self *Webservice) AddServicesContext(ctx context.Context, urls ...string) (err error) {
 	for _, u := range urls {
-		var resp *http.Response
-		resp, err = http.Get(u)
+		err = self.addService(ctx, u)
 		if err != nil {
 			return
 		}
-		defer resp.Body.Close()
+	}
+
+	return
+}
+
+// AddServiceFromReader adds the services of the WSDL read from r. Imports
+// have to be absolute URLs, which are fetched through the client of the
+// webservice.
+func (self *Webservice) AddServiceFromReader(r io.Reader) (err error) {
+	err = self.add(context.Background(), r, wsdl.SplitFetcher{Remote: wsdl.HTTPFetcher{Client: self.Client}}, "")
+	return
+}
+
+// AddServiceFromFile adds the services of the WSDL in the file name, whose
+// imports are resolved relative to the file.
+func (self *Webservice) AddServiceFromFile(name string) (err error) {
+	var s *wsdl.Definitions
+	s, err = wsdl.ReadFile(context.Background(), name, wsdl.SplitFetcher{
+		Local:  wsdl.FileFetcher{},
+		Remote: wsdl.HTTPFetcher{Client: self.Client},
+	})
+	if err != nil {
+		return
+	}
+
+	err = self.register(s, name)
+	return
+}
+
+// AddServicesFromFS adds the services of the WSDLs in fsys, e.g. an
+// embed.FS, matching any of patterns like "wsdl/*.wsdl", or all WSDLs in
+// the root of fsys if there are no patterns. Imports are resolved relative
+// to the WSDLs within fsys.
+func (self *Webservice) AddServicesFromFS(fsys fs.FS, patterns ...string) (err error) {
+	if len(patterns) == 0 {
+		patterns = []string{"*.wsdl"}
+	}
+
+	f := wsdl.SplitFetcher{
+		Local:  wsdl.FSFetcher{FS: fsys},
+		Remote: wsdl.HTTPFetcher{Client: self.Client},
+	}
 
-		s := new(wsdl.Definitions)
-		err = xml.NewDecoder(resp.Body).Decode(s)
+	for _, pattern := range patterns {
+		var names []string
+		names, err = fs.Glob(fsys, pattern)
 		if err != nil {
 			return
 		}
 
-		if s.Service.Name == "" {
-			err = fmt.Errorf("invalid service name '%s' for url '%s'", s.Service.Name, u)
+		if len(names) == 0 {
+			err = fmt.Errorf("no WSDL matches '%s'", pattern)
 			return
 		}
 
-		self.services[s.Service.Name] = s
-		log.Printf("adding service '%s' from '%s'", s.Service.Name, u)
+		for _, name := range names {
+			err = self.addServiceFromFS(fsys, f, name)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func (self *Webservice) addServiceFromFS(fsys fs.FS, f wsdl.Fetcher, name string) (err error) {
+	var file fs.File
+	file, err = fsys.Open(name)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	err = self.add(context.Background(), file, f, name)
+	return
+}
+
+func (self *Webservice) addService(ctx context.Context, u string) (err error) {
+	f := wsdl.HTTPFetcher{Client: self.Client}
+
+	var rc io.ReadCloser
+	rc, err = f.Fetch(ctx, u)
+	if err != nil {
+		return
+	}
+	defer rc.Close()
+
+	err = self.add(ctx, rc, f, u)
+	return
+}
+
+// add adds the services of the WSDL read from r, whose imports are fetched
+// by f relative to its location.
+func (self *Webservice) add(ctx context.Context, r io.Reader, f wsdl.Fetcher, location string) (err error) {
+	var s *wsdl.Definitions
+	s, err = wsdl.Read(ctx, ctxReader{ctx, r}, f, location)
+	if err != nil {
+		return
+	}
+
+	err = self.register(s, location)
+	return
+}
+
+// register adds the services of the definitions s loaded from location.
+// Services of a name added before are not replaced but fail with an error.
+func (self *Webservice) register(s *wsdl.Definitions, location string) (err error) {
+	if len(s.Services) == 0 {
+		err = fmt.Errorf("no service in '%s'", location)
+		return
+	}
+
+	for _, svc := range s.Services {
+		if svc.Name == "" || strings.Contains(svc.Name, "/") {
+			err = fmt.Errorf("invalid service name '%s' in '%s'", svc.Name, location)
+			return
+		}
+
+		if _, ok := self.services[svc.Name]; ok {
+			err = fmt.Errorf("service '%s' in '%s' has already been added", svc.Name, location)
+			return
+		}
+	}
+
+	for space, prefix := range self.Prefixes {
+		s.Prefixes[space] = prefix
+	}
+	s.Strict = self.Strict
+
+	for _, svc := range s.Services {
+		self.services[svc.Name] = s
+		self.logger().Printf("adding service '%s' from '%s'", svc.Name, location)
+	}
+	return
+}
+
+// endpoint returns the URL a request of operation of service is posted to,
+// which is the one set by WithEndpoint, by Endpoints for the port or the
+// service the operation is sent through or the address of the port, passed
+// through Rewrite.
+func (self *Webservice) endpoint(ctx context.Context, s *wsdl.Definitions, service, operation string) (endpoint string, err error) {
+	endpoint, ok := ctx.Value(endpointKey{}).(string)
+	if !ok {
+		var port wsdl.ServicePort
+		var name string
+		port, name, err = s.Port(operation)
+		if err != nil {
+			return
+		}
+
+		endpoint, ok = self.Endpoints[name+"/"+port.Name]
+		if !ok {
+			endpoint, ok = self.Endpoints[name]
+		}
+		if !ok {
+			endpoint = port.Address.Location
+		}
+	}
+
+	if self.Rewrite == nil {
+		return
+	}
+
+	var u *url.URL
+	u, err = url.Parse(endpoint)
+	if err != nil {
+		return
+	}
+
+	self.Rewrite(service, u)
+	endpoint = u.String()
+	return
+}
+
+// service returns the definitions of service along with method qualified by
+// service, see wsdl.Definitions. The service is either a name or qualified
+// by a port like "service/port", which selects the port the operation is
+// sent through.
+func (self *Webservice) service(service, method string) (s *wsdl.Definitions, operation string, err error) {
+	name := strings.SplitN(service, "/", 2)[0]
+	s = self.services[name]
+	if s == nil {
+		err = fmt.Errorf("no such service '%s'", service)
+		return
 	}
 
+	operation = service + "/" + method
 	return
 }
@@ -0,0 +1,368 @@
+package goat
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/justwatchcom/goat/wsdl"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const endpointWSDL = `<definitions xmlns="http://schemas.xmlsoap.org/wsdl/" xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/" xmlns:tns="urn:service" targetNamespace="urn:service">
+  <portType name="ServicePort"><operation name="get"/></portType>
+  <binding name="ServiceSoap" type="tns:ServicePort">
+    <soap:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <operation name="get"/>
+  </binding>
+  <service name="Service">
+    <port name="ServiceSoap" binding="tns:ServiceSoap"><soap:address location="http://internal:8080/api/Service"/></port>
+  </service>
+</definitions>`
+
+type endpointTestCase struct {
+	comment        string
+	service        string
+	ctx            context.Context
+	endpoints      map[string]string
+	rewrite        func(string, *url.URL)
+	expectedResult string
+}
+
+var (
+	endpointTestCases = []endpointTestCase{
+		{
+			comment:        "address of the WSDL",
+			service:        "Service",
+			expectedResult: "http://internal:8080/api/Service",
+		},
+		{
+			comment:        "endpoint of the service",
+			service:        "Service",
+			endpoints:      map[string]string{"Service": "https://sandbox.example.com/api/Service"},
+			expectedResult: "https://sandbox.example.com/api/Service",
+		},
+		{
+			comment: "endpoint of the port",
+			service: "Service/ServiceSoap",
+			endpoints: map[string]string{
+				"Service":             "https://sandbox.example.com/api/Service",
+				"Service/ServiceSoap": "https://soap.example.com/api/Service",
+			},
+			expectedResult: "https://soap.example.com/api/Service",
+		},
+		{
+			comment: "endpoint of the default port",
+			service: "Service",
+			endpoints: map[string]string{
+				"Service":             "https://sandbox.example.com/api/Service",
+				"Service/ServiceSoap": "https://soap.example.com/api/Service",
+			},
+			expectedResult: "https://soap.example.com/api/Service",
+		},
+		{
+			comment:        "endpoint of the request",
+			service:        "Service",
+			ctx:            WithEndpoint(context.Background(), "https://staging.example.com/api/Service"),
+			endpoints:      map[string]string{"Service": "https://sandbox.example.com/api/Service"},
+			expectedResult: "https://staging.example.com/api/Service",
+		},
+		{
+			comment: "rewritten host",
+			service: "Service",
+			rewrite: func(service string, u *url.URL) {
+				u.Scheme, u.Host = "https", "api.example.com"
+			},
+			expectedResult: "https://api.example.com/api/Service",
+		},
+	}
+)
+
+func TestWebservice_Endpoint(t *testing.T) {
+	Convey("given a webservice", t, func() {
+		s := new(wsdl.Definitions)
+		err := xml.Unmarshal([]byte(endpointWSDL), s)
+		So(err, ShouldBeNil)
+
+		for _, c := range endpointTestCases {
+			Convey(fmt.Sprintf("test case '%s'", c.comment), func() {
+				ws := NewWebservice(nil, nil)
+				ws.services["Service"] = s
+				ws.Endpoints = c.endpoints
+				ws.Rewrite = c.rewrite
+
+				ctx := c.ctx
+				if ctx == nil {
+					ctx = context.Background()
+				}
+
+				ds, operation, err := ws.service(c.service, "get")
+				So(err, ShouldBeNil)
+
+				endpoint, err := ws.endpoint(ctx, ds, c.service, operation)
+				So(err, ShouldBeNil)
+				So(endpoint, ShouldEqual, c.expectedResult)
+			})
+		}
+	})
+}
+
+var serviceFS = fstest.MapFS{
+	"wsdl/service.wsdl": {Data: []byte(`<definitions xmlns="http://schemas.xmlsoap.org/wsdl/" xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:service" targetNamespace="urn:service">
+  <types>
+    <xsd:schema targetNamespace="urn:service">
+      <xsd:include schemaLocation="../xsd/types.xsd"/>
+    </xsd:schema>
+  </types>
+  <service name="Service">
+    <port name="ServiceSoap" binding="tns:ServiceSoap"><soap:address location="http://localhost/service"/></port>
+  </service>
+</definitions>`)},
+	"xsd/types.xsd": {Data: []byte(`<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema">
+  <xsd:element name="get" type="xsd:string"/>
+</xsd:schema>`)},
+}
+
+func TestWebservice_AddServicesFromFS(t *testing.T) {
+	Convey("given WSDLs in a file system", t, func() {
+		ws := NewWebservice(nil, nil)
+		err := ws.AddServicesFromFS(serviceFS, "wsdl/*.wsdl")
+		So(err, ShouldBeNil)
+		So(ws.services["Service"], ShouldNotBeNil)
+		So(ws.services["Service"].Types.Schemas.GetElement("urn:service", "get"), ShouldNotBeNil)
+
+		err = ws.AddServicesFromFS(serviceFS)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestWebservice_AddServiceFromFile(t *testing.T) {
+	Convey("given WSDLs in a directory", t, func() {
+		dir := t.TempDir()
+		for name, f := range serviceFS {
+			err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+			So(err, ShouldBeNil)
+			err = os.WriteFile(filepath.Join(dir, name), f.Data, 0644)
+			So(err, ShouldBeNil)
+		}
+
+		ws := NewWebservice(nil, nil)
+		err := ws.AddServiceFromFile(filepath.Join(dir, "wsdl", "service.wsdl"))
+		So(err, ShouldBeNil)
+		So(ws.services["Service"], ShouldNotBeNil)
+		So(ws.services["Service"].Types.Schemas.GetElement("urn:service", "get"), ShouldNotBeNil)
+
+		Convey("test case 'service added twice'", func() {
+			s := ws.services["Service"]
+			err := ws.AddServiceFromFile(filepath.Join(dir, "wsdl", "service.wsdl"))
+			So(err, ShouldNotBeNil)
+			So(ws.services["Service"], ShouldEqual, s)
+		})
+	})
+}
+
+func TestWebservice_AddServiceFromReader(t *testing.T) {
+	Convey("given a WSDL without imports", t, func() {
+		ws := NewWebservice(nil, nil)
+		err := ws.AddServiceFromReader(strings.NewReader(serviceWSDL))
+		So(err, ShouldBeNil)
+		So(ws.services["Service"], ShouldNotBeNil)
+	})
+
+	Convey("given a WSDL with relative imports", t, func() {
+		ws := NewWebservice(nil, nil)
+		err := ws.AddServiceFromReader(bytes.NewReader(serviceFS["wsdl/service.wsdl"].Data))
+		So(err, ShouldNotBeNil)
+		So(ws.services["Service"], ShouldBeNil)
+	})
+}
+
+const serviceWSDL = `<definitions xmlns="http://schemas.xmlsoap.org/wsdl/" xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:service" targetNamespace="urn:service">
+  <types>
+    <xsd:schema targetNamespace="urn:service" elementFormDefault="qualified">
+      <xsd:element name="ResponseHeader">
+        <xsd:complexType><xsd:sequence><xsd:element name="requestId" type="xsd:string"/></xsd:sequence></xsd:complexType>
+      </xsd:element>
+      <xsd:element name="ApiException">
+        <xsd:complexType><xsd:sequence><xsd:element name="message" type="xsd:string"/></xsd:sequence></xsd:complexType>
+      </xsd:element>
+      <xsd:element name="get">
+        <xsd:complexType><xsd:sequence><xsd:element name="id" type="xsd:int"/></xsd:sequence></xsd:complexType>
+      </xsd:element>
+      <xsd:element name="getResponse">
+        <xsd:complexType><xsd:sequence><xsd:element name="rval" type="xsd:string" maxOccurs="unbounded"/></xsd:sequence></xsd:complexType>
+      </xsd:element>
+    </xsd:schema>
+  </types>
+  <message name="getRequest"><part name="parameters" element="tns:get"/></message>
+  <message name="getResponse"><part name="parameters" element="tns:getResponse"/></message>
+  <message name="ResponseHeader"><part name="ResponseHeader" element="tns:ResponseHeader"/></message>
+  <message name="ApiException"><part name="fault" element="tns:ApiException"/></message>
+  <portType name="ServicePort">
+    <operation name="get">
+      <input message="tns:getRequest"/>
+      <output message="tns:getResponse"/>
+      <fault name="ApiExceptionFault" message="tns:ApiException"/>
+    </operation>
+  </portType>
+  <binding name="ServiceSoap" type="tns:ServicePort">
+    <soap:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <operation name="get">
+      <soap:operation soapAction="urn:get"/>
+      <input><soap:body use="literal"/></input>
+      <output><soap:header message="tns:ResponseHeader" part="ResponseHeader" use="literal"/><soap:body use="literal"/></output>
+      <fault name="ApiExceptionFault"><soap:fault name="ApiExceptionFault" use="literal"/></fault>
+    </operation>
+  </binding>
+  <service name="Service">
+    <port name="ServiceSoap" binding="tns:ServiceSoap"><soap:address location="http://localhost/service"/></port>
+  </service>
+</definitions>`
+
+type contextTestCase struct {
+	comment string
+	ctx     func() (context.Context, context.CancelFunc)
+}
+
+var (
+	contextTestCases = []contextTestCase{
+		{
+			comment: "canceled context",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+		},
+		{
+			comment: "expired deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+			},
+		},
+	}
+)
+
+func TestWebservice_DoContext(t *testing.T) {
+	Convey("given a server which does not respond", t, func() {
+		done := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+		}))
+		defer server.Close()
+		defer close(done)
+
+		s := new(wsdl.Definitions)
+		err := xml.Unmarshal([]byte(serviceWSDL), s)
+		So(err, ShouldBeNil)
+
+		for _, c := range contextTestCases {
+			Convey(fmt.Sprintf("test case '%s'", c.comment), func() {
+				ws := NewWebservice(nil, nil)
+				ws.services["Service"] = s
+
+				ctx, cancel := c.ctx()
+				defer cancel()
+
+				var res map[string]interface{}
+				err := ws.DoContext(WithEndpoint(ctx, server.URL), "Service", "get", &res, map[string]interface{}{"get/id": 1})
+				So(err, ShouldNotBeNil)
+				So(errors.Is(err, ctx.Err()), ShouldBeTrue)
+
+				ctx, cancel = c.ctx()
+				defer cancel()
+
+				err = ws.AddServicesContext(ctx, server.URL+"/service?wsdl")
+				So(err, ShouldNotBeNil)
+				So(errors.Is(err, ctx.Err()), ShouldBeTrue)
+			})
+		}
+	})
+}
+
+func TestWebservice_DoHeaderContext(t *testing.T) {
+	Convey("given a server responding with a header and a body", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/">
+  <soapenv:Header><ResponseHeader xmlns="urn:service"><requestId>abc</requestId></ResponseHeader></soapenv:Header>
+  <soapenv:Body><getResponse xmlns="urn:service"><rval>a</rval><rval>b</rval></getResponse></soapenv:Body>
+</soapenv:Envelope>`)
+		}))
+		defer server.Close()
+
+		s := new(wsdl.Definitions)
+		err := xml.Unmarshal([]byte(serviceWSDL), s)
+		So(err, ShouldBeNil)
+
+		ws := NewWebservice(nil, nil)
+		ws.services["Service"] = s
+		ws.Endpoints = map[string]string{"Service": server.URL}
+
+		var header, res map[string]interface{}
+		err = ws.DoHeaderContext(context.Background(), "Service", "get", &header, &res, map[string]interface{}{"get/id": 1})
+		So(err, ShouldBeNil)
+		So(fmt.Sprint(header), ShouldEqual, "map[requestId:abc]")
+		So(fmt.Sprint(res), ShouldEqual, "map[rval:[a b]]")
+
+		Convey("test case 'output without header'", func() {
+			s := new(wsdl.Definitions)
+			err := xml.Unmarshal([]byte(strings.Replace(serviceWSDL, `<soap:header message="tns:ResponseHeader" part="ResponseHeader" use="literal"/>`, "", 1)), s)
+			So(err, ShouldBeNil)
+			ws.services["Service"] = s
+
+			header := map[string]interface{}{"requestId": "stale"}
+			err = ws.DoHeaderContext(context.Background(), "Service", "get", &header, &res, map[string]interface{}{"get/id": 1})
+			So(err, ShouldBeNil)
+			So(header, ShouldBeNil)
+		})
+	})
+}
+
+func TestWebservice_SendBuffer(t *testing.T) {
+	Convey("given a server responding to the action of the request", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("SOAPAction") != `"urn:get"` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/">
+  <soapenv:Body><getResponse xmlns="urn:service"><rval>a</rval></getResponse></soapenv:Body>
+</soapenv:Envelope>`)
+		}))
+		defer server.Close()
+
+		s := new(wsdl.Definitions)
+		err := xml.Unmarshal([]byte(serviceWSDL), s)
+		So(err, ShouldBeNil)
+
+		ws := NewWebservice(nil, nil)
+		ws.services["Service"] = s
+		ws.Endpoints = map[string]string{"Service": server.URL}
+
+		var res map[string]interface{}
+		err = ws.SendBuffer("Service", &res, strings.NewReader(`<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/">
+  <soapenv:Body><get xmlns="urn:service"><id>1</id></get></soapenv:Body>
+</soapenv:Envelope>`))
+		So(err, ShouldBeNil)
+		So(fmt.Sprint(res), ShouldEqual, "map[rval:[a]]")
+
+		err = ws.SendBuffer("Service", &res, strings.NewReader(`<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/">
+  <soapenv:Body><get xmlns="urn:other"><id>1</id></get></soapenv:Body>
+</soapenv:Envelope>`))
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,358 @@
+package wsdl
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/justwatchcom/goat/xsd"
+)
+
+type Import struct {
+	Namespace string `xml:"namespace,attr"`
+	Location  string `xml:"location,attr"`
+}
+
+// Fetcher retrieves the documents referenced by wsdl:import, xsd:import and
+// xsd:include elements.
+type Fetcher interface {
+	Fetch(ctx context.Context, location string) (io.ReadCloser, error)
+}
+
+// HTTPFetcher fetches documents with its Client, or http.DefaultClient if
+// Client is nil.
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+func (self HTTPFetcher) Fetch(ctx context.Context, location string) (rc io.ReadCloser, err error) {
+	c := self.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	var req *http.Request
+	req, err = http.NewRequestWithContext(ctx, "GET", location, nil)
+	if err != nil {
+		return
+	}
+
+	var resp *http.Response
+	resp, err = c.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err = fmt.Errorf("unexpected status '%s' for url '%s'", resp.Status, location)
+		return
+	}
+
+	rc = resp.Body
+	return
+}
+
+// FileFetcher fetches documents from the local file system.
+type FileFetcher struct{}
+
+func (FileFetcher) Fetch(ctx context.Context, location string) (io.ReadCloser, error) {
+	return os.Open(filepath.FromSlash(strings.TrimPrefix(location, "file://")))
+}
+
+// FSFetcher fetches documents from FS, e.g. an embed.FS.
+type FSFetcher struct {
+	FS fs.FS
+}
+
+func (self FSFetcher) Fetch(ctx context.Context, location string) (io.ReadCloser, error) {
+	return self.FS.Open(location)
+}
+
+// SplitFetcher fetches documents at absolute http and https URLs with Remote
+// and all others with Local. Without Local, relative locations cannot be
+// fetched, e.g. those of a WSDL read from memory.
+type SplitFetcher struct {
+	Local  Fetcher
+	Remote Fetcher
+}
+
+func (self SplitFetcher) Fetch(ctx context.Context, location string) (rc io.ReadCloser, err error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		rc, err = self.Remote.Fetch(ctx, location)
+		return
+	}
+
+	if self.Local == nil {
+		err = fmt.Errorf("cannot fetch relative location '%s'", location)
+		return
+	}
+
+	rc, err = self.Local.Fetch(ctx, location)
+	return
+}
+
+// Read decodes the definitions read from r, which are located at location,
+// and resolves their imports with f, see ResolveImports.
+func Read(ctx context.Context, r io.Reader, f Fetcher, location string) (d *Definitions, err error) {
+	d = new(Definitions)
+	err = xml.NewDecoder(r).Decode(d)
+	if err != nil {
+		return
+	}
+
+	err = d.ResolveImports(ctx, f, location)
+	return
+}
+
+// ReadFile reads the definitions in the file name like Read, resolving
+// imports relative to the file.
+func ReadFile(ctx context.Context, name string, f Fetcher) (d *Definitions, err error) {
+	var file *os.File
+	file, err = os.Open(name)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	d, err = Read(ctx, file, f, filepath.ToSlash(name))
+	return
+}
+
+// resolveLocation resolves the location ref relative to the location base of
+// the referencing document. base is either a URL or a slash separated path.
+func resolveLocation(base, ref string) (location string, err error) {
+	var r, b *url.URL
+	r, err = url.Parse(ref)
+	if err != nil {
+		return
+	}
+
+	if r.IsAbs() {
+		location = ref
+		return
+	}
+
+	// host relative refs like "/schemas/types.xsd" keep the scheme and host
+	// of a URL, while they are absolute paths otherwise
+	b, err = url.Parse(base)
+	if err == nil && b.IsAbs() {
+		location = b.ResolveReference(r).String()
+		return
+	}
+
+	err = nil
+	if strings.HasPrefix(ref, "/") {
+		location = ref
+		return
+	}
+
+	location = path.Join(path.Dir(base), ref)
+	return
+}
+
+// ResolveImports follows the wsdl:import, xsd:import and xsd:include elements
+// of the definitions loaded from location. The referenced documents are
+// resolved relative to their referencing document, retrieved by f and merged
+// into the definitions and Types.Schemas. Every location is only visited
+// once, which breaks import cycles.
+func (self *Definitions) ResolveImports(ctx context.Context, f Fetcher, location string) (err error) {
+	r := &resolver{
+		ctx:     ctx,
+		fetcher: f,
+		visited: map[string]bool{location: true},
+	}
+
+	err = r.definitions(self, location)
+	return
+}
+
+type resolver struct {
+	ctx     context.Context
+	fetcher Fetcher
+	visited map[string]bool
+}
+
+func (self *resolver) fetch(base, ref string, v interface{}) (location string, skip bool, err error) {
+	location, err = resolveLocation(base, ref)
+	if err != nil {
+		return
+	}
+
+	if self.visited[location] {
+		skip = true
+		return
+	}
+	self.visited[location] = true
+
+	var rc io.ReadCloser
+	rc, err = self.fetcher.Fetch(self.ctx, location)
+	if err != nil {
+		if self.ctx.Err() == nil {
+			err = fmt.Errorf("could not fetch '%s': %s", location, err)
+		}
+		return
+	}
+	defer rc.Close()
+
+	err = xml.NewDecoder(rc).Decode(v)
+	if err != nil {
+		err = fmt.Errorf("could not decode '%s': %s", location, err)
+	}
+	return
+}
+
+func (self *resolver) definitions(d *Definitions, location string) (err error) {
+	// schemas of imported definitions are resolved relative to their own
+	// location
+	schemata := d.Types.Schemata
+
+	for _, imp := range d.Imports {
+		if imp.Location == "" {
+			continue
+		}
+
+		sub := new(Definitions)
+		var loc string
+		var skip bool
+		loc, skip, err = self.fetch(location, imp.Location, sub)
+		if err != nil {
+			return
+		} else if skip {
+			continue
+		}
+
+		err = self.definitions(sub, loc)
+		if err != nil {
+			return
+		}
+
+		d.merge(sub)
+	}
+
+	for _, schema := range schemata {
+		err = self.schema(d.Types.Schemas, schema, location)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (self *resolver) schema(sm xsd.SchemaMap, schema xsd.Schema, location string) (err error) {
+	for _, inc := range schema.Includes {
+		sub := xsd.Schema{}
+		var loc string
+		var skip bool
+		loc, skip, err = self.fetch(location, inc.SchemaLocation, &sub)
+		if err != nil {
+			return
+		} else if skip {
+			continue
+		}
+
+		// an included schema without target namespace takes the one of
+		// the including schema
+		if sub.TargetNamespace == "" {
+			sub.TargetNamespace = schema.TargetNamespace
+		}
+
+		sm.Add(sub)
+		err = self.schema(sm, sub, loc)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, imp := range schema.Imports {
+		if imp.SchemaLocation == "" {
+			continue
+		}
+
+		sub := xsd.Schema{}
+		var loc string
+		var skip bool
+		loc, skip, err = self.fetch(location, imp.SchemaLocation, &sub)
+		if err != nil {
+			return
+		} else if skip {
+			continue
+		}
+
+		if imp.Namespace != "" && sub.TargetNamespace != imp.Namespace {
+			err = fmt.Errorf("have '%s', want '%s' as target namespace of '%s'", sub.TargetNamespace, imp.Namespace, loc)
+			return
+		}
+
+		sm.Add(sub)
+		err = self.schema(sm, sub, loc)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// merge adds the messages, port types, bindings, services and schemas of the
+// imported definitions other. Messages, port types and bindings keep the
+// target namespace and the aliases of other, so that qualified names referring
+// to them resolve by namespace rather than by the merged aliases.
+func (self *Definitions) merge(other *Definitions) {
+	for i := range other.Messages {
+		if other.Messages[i].Aliases == nil {
+			other.Messages[i].Aliases = other.Aliases
+		}
+	}
+
+	for i := range other.PortTypes {
+		if other.PortTypes[i].TargetNamespace == "" {
+			other.PortTypes[i].TargetNamespace = other.TargetNamespace
+		}
+	}
+
+	for i := range other.Binding {
+		if other.Binding[i].TargetNamespace == "" {
+			other.Binding[i].TargetNamespace = other.TargetNamespace
+			other.Binding[i].Aliases = other.Aliases
+		}
+	}
+
+	self.Messages = append(self.Messages, other.Messages...)
+	self.Binding = append(self.Binding, other.Binding...)
+	self.Types.Schemata = append(self.Types.Schemata, other.Types.Schemata...)
+	for _, schema := range other.Types.Schemas {
+		self.Types.Schemas.Add(schema)
+	}
+
+	self.PortTypes = append(self.PortTypes, other.PortTypes...)
+	self.Services = append(self.Services, other.Services...)
+
+	for k, v := range other.Aliases {
+		if _, ok := self.Aliases[k]; !ok {
+			self.Aliases[k] = v
+		}
+	}
+
+	if self.Prefixes == nil {
+		self.Prefixes = map[string]string{}
+	}
+
+	for k, v := range other.Prefixes {
+		if _, ok := self.Prefixes[k]; !ok {
+			self.Prefixes[k] = v
+		}
+	}
+}
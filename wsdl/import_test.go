@@ -0,0 +1,150 @@
+package wsdl
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type resolveLocationTestCase struct {
+	base, ref, expectedResult string
+}
+
+var (
+	resolveLocationTestCases = []resolveLocationTestCase{
+		{"wsdl/service.wsdl", "../xsd/types.xsd", "xsd/types.xsd"},
+		{"service.wsdl", "types.xsd", "types.xsd"},
+		{"/srv/wsdl/service.wsdl", "types.xsd", "/srv/wsdl/types.xsd"},
+		{"http://example.com/Service.svc?wsdl", "Service.svc?xsd=xsd0", "http://example.com/Service.svc?xsd=xsd0"},
+		{"http://example.com/a/service.wsdl", "../types.xsd", "http://example.com/types.xsd"},
+		{"service.wsdl", "http://example.com/types.xsd", "http://example.com/types.xsd"},
+		{"https://example.com/api/service.wsdl", "/schemas/types.xsd", "https://example.com/schemas/types.xsd"},
+		{"wsdl/service.wsdl", "/srv/xsd/types.xsd", "/srv/xsd/types.xsd"},
+	}
+
+	importFS = fstest.MapFS{
+		"service.wsdl": {Data: []byte(`<definitions xmlns="http://schemas.xmlsoap.org/wsdl/" xmlns:tns="urn:service" targetNamespace="urn:service">
+  <import namespace="urn:service" location="wsdl/binding.wsdl"/>
+  <service name="Service"/>
+</definitions>`)},
+		"wsdl/binding.wsdl": {Data: []byte(`<definitions xmlns="http://schemas.xmlsoap.org/wsdl/" xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:service">
+  <types>
+    <xsd:schema targetNamespace="urn:service">
+      <xsd:import namespace="urn:types" schemaLocation="../xsd/types.xsd"/>
+      <xsd:element name="get" type="xsd:string"/>
+    </xsd:schema>
+  </types>
+  <message name="getRequest"/>
+</definitions>`)},
+		"xsd/types.xsd": {Data: []byte(`<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:types">
+  <xsd:include schemaLocation="more/types.xsd"/>
+  <xsd:element name="first" type="xsd:string"/>
+</xsd:schema>`)},
+		"xsd/more/types.xsd": {Data: []byte(`<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema">
+  <xsd:include schemaLocation="../types.xsd"/>
+  <xsd:element name="second" type="xsd:string"/>
+</xsd:schema>`)},
+	}
+	// the layout of WCF services, whose binding refers to the port type of
+	// the imported contract namespace
+	contractFS = fstest.MapFS{
+		"service.wsdl": {Data: []byte(`<wsdl:definitions xmlns:wsdl="http://schemas.xmlsoap.org/wsdl/" xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/" xmlns:tns="http://tempuri.org/" xmlns:i0="urn:contract" targetNamespace="http://tempuri.org/">
+  <wsdl:import namespace="urn:contract" location="service.wsdl?wsdl=wsdl0"/>
+  <wsdl:binding name="BasicHttpBinding_IService" type="i0:IService">
+    <soap:binding transport="http://schemas.xmlsoap.org/soap/http"/>
+    <wsdl:operation name="Get">
+      <soap:operation soapAction="urn:contract/IService/Get" style="document"/>
+      <wsdl:input><soap:body use="literal"/></wsdl:input>
+      <wsdl:output><soap:body use="literal"/></wsdl:output>
+    </wsdl:operation>
+  </wsdl:binding>
+  <wsdl:service name="Service">
+    <wsdl:port name="BasicHttpBinding_IService" binding="tns:BasicHttpBinding_IService"><soap:address location="http://localhost/Service.svc"/></wsdl:port>
+  </wsdl:service>
+</wsdl:definitions>`)},
+		"service.wsdl?wsdl=wsdl0": {Data: []byte(`<wsdl:definitions xmlns:wsdl="http://schemas.xmlsoap.org/wsdl/" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:contract" targetNamespace="urn:contract">
+  <wsdl:types>
+    <xsd:schema targetNamespace="urn:contract" elementFormDefault="qualified">
+      <xsd:element name="Get">
+        <xsd:complexType><xsd:sequence><xsd:element name="id" type="xsd:int"/></xsd:sequence></xsd:complexType>
+      </xsd:element>
+      <xsd:element name="GetResponse">
+        <xsd:complexType><xsd:sequence><xsd:element name="GetResult" type="xsd:string"/></xsd:sequence></xsd:complexType>
+      </xsd:element>
+    </xsd:schema>
+  </wsdl:types>
+  <wsdl:message name="IService_Get_InputMessage"><wsdl:part name="parameters" element="tns:Get"/></wsdl:message>
+  <wsdl:message name="IService_Get_OutputMessage"><wsdl:part name="parameters" element="tns:GetResponse"/></wsdl:message>
+  <wsdl:portType name="IService">
+    <wsdl:operation name="Get">
+      <wsdl:input message="tns:IService_Get_InputMessage"/>
+      <wsdl:output message="tns:IService_Get_OutputMessage"/>
+    </wsdl:operation>
+  </wsdl:portType>
+</wsdl:definitions>`)},
+	}
+)
+
+func TestResolveLocation(t *testing.T) {
+	Convey("given a base and a referenced location", t, func() {
+		for _, c := range resolveLocationTestCases {
+			Convey(fmt.Sprintf("test case '%s' from '%s'", c.ref, c.base), func() {
+				location, err := resolveLocation(c.base, c.ref)
+				So(err, ShouldBeNil)
+				So(location, ShouldEqual, c.expectedResult)
+			})
+		}
+	})
+}
+
+func TestDefinitions_ResolveImports(t *testing.T) {
+	Convey("given definitions importing definitions and schemas", t, func() {
+		f, err := importFS.Open("service.wsdl")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		d := new(Definitions)
+		err = xml.NewDecoder(f).Decode(d)
+		So(err, ShouldBeNil)
+
+		err = d.ResolveImports(context.Background(), FSFetcher{importFS}, "service.wsdl")
+		So(err, ShouldBeNil)
+
+		So(len(d.Services), ShouldEqual, 1)
+		So(d.Services[0].Name, ShouldEqual, "Service")
+		So(len(d.Messages), ShouldEqual, 1)
+		So(d.Types.Schemas.GetElement("urn:service", "get"), ShouldNotBeNil)
+		So(d.Types.Schemas.GetElement("urn:types", "first"), ShouldNotBeNil)
+		So(d.Types.Schemas.GetElement("urn:types", "second"), ShouldNotBeNil)
+		So(len(d.Types.Schemas["urn:types"].Elements), ShouldEqual, 2)
+	})
+}
+
+func TestDefinitions_ResolveContractImport(t *testing.T) {
+	Convey("given definitions binding the port type of imported definitions", t, func() {
+		f, err := contractFS.Open("service.wsdl")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		d, err := Read(context.Background(), f, FSFetcher{contractFS}, "service.wsdl")
+		So(err, ShouldBeNil)
+
+		Convey("test case 'request'", func() {
+			var b strings.Builder
+			err := d.WriteRequest("Get", &b, nil, map[string]interface{}{"Get/id": 1})
+			So(err, ShouldBeNil)
+			So(b.String(), ShouldContainSubstring, "<i0:Get>\n      <i0:id>1</i0:id>")
+		})
+
+		Convey("test case 'response'", func() {
+			res, err := d.ReadResponse("Get", strings.NewReader(`<GetResponse xmlns="urn:contract"><GetResult>a</GetResult></GetResponse>`))
+			So(err, ShouldBeNil)
+			So(fmt.Sprint(res), ShouldEqual, "map[GetResult:a]")
+		})
+	})
+}
@@ -14,6 +14,9 @@ type Type struct {
 type Message struct {
 	Name string `xml:"name,attr"`
 	Part Part   `xml:"part"`
+	// Aliases are those of the imported definitions declaring the message,
+	// which resolve the element of its part, see merge.
+	Aliases map[string]string `xml:"-"`
 }
 
 type Part struct {
@@ -25,18 +28,24 @@ type PortType struct {
 	Name          string              `xml:"name,attr"`
 	Documentation string              `xml:"documentation"`
 	Operations    []PortTypeOperation `xml:"operation"`
+	// TargetNamespace is the one of the imported definitions declaring the
+	// port type, which bindings refer to it in, see merge.
+	TargetNamespace string `xml:"-"`
 }
 
 type PortTypeOperation struct {
 	Name   string                   `xml:"name,attr"`
 	Input  PortTypeOperationMessage `xml:"input"`
 	Output PortTypeOperationMessage `xml:"output"`
-	Fault  PortTypeOperationMessage `xml:"fault"`
+	// Fault is the first of Faults.
+	Fault  PortTypeOperationMessage   `xml:"-"`
+	Faults []PortTypeOperationMessage `xml:"fault"`
 }
 
 type PortTypeOperationMessage struct {
 	Name    string `xml:"name,attr"`
 	Message string `xml:"message,attr"`
+	Action  string `xml:"Action,attr"`
 }
 
 type Binding struct {
@@ -44,6 +53,11 @@ type Binding struct {
 	Type        string             `xml:"type,attr"`
 	SoapBinding SoapBinding        `xml:"binding"`
 	Operations  []BindingOperation `xml:"operation"`
+	// TargetNamespace and Aliases are those of the imported definitions
+	// declaring the binding, which ports refer to it in and which resolve
+	// its type, see merge.
+	TargetNamespace string            `xml:"-"`
+	Aliases         map[string]string `xml:"-"`
 }
 
 type SoapBinding struct {
@@ -57,7 +71,14 @@ type BindingOperation struct {
 	SoapOperation SoapOperation `xml:"operation"`
 	Input         SoapBodyIO    `xml:"input"`
 	Output        SoapBodyIO    `xml:"output"`
-	Fault         SoapBody      `xml:"fault>fault"`
+	// Fault is the soap:fault of the first of Faults.
+	Fault  SoapBody       `xml:"-"`
+	Faults []BindingFault `xml:"fault"`
+}
+
+type BindingFault struct {
+	Name      string   `xml:"name,attr"`
+	SoapFault SoapBody `xml:"fault"`
 }
 
 type SoapOperation struct {
@@ -77,8 +98,8 @@ type SoapBody struct {
 }
 
 type Service struct {
-	Name string      `xml:"name,attr"`
-	Port ServicePort `xml:"port"`
+	Name  string        `xml:"name,attr"`
+	Ports []ServicePort `xml:"port"`
 }
 
 type ServicePort struct {
@@ -0,0 +1,130 @@
+package wsdl
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// Namespaces of the SOAP 1.1 and 1.2 WSDL bindings.
+	SoapBindingNamespace   = "http://schemas.xmlsoap.org/wsdl/soap/"
+	Soap12BindingNamespace = "http://schemas.xmlsoap.org/wsdl/soap12/"
+
+	// Namespaces of the SOAP 1.1 and 1.2 envelopes.
+	SoapEnvelopeNamespace   = "http://schemas.xmlsoap.org/soap/envelope/"
+	Soap12EnvelopeNamespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+// IsSoap12 reports whether the binding is a soap12 binding.
+func (self *Binding) IsSoap12() bool {
+	return self.SoapBinding.XMLName.Space == Soap12BindingNamespace
+}
+
+// IsSoap reports whether the binding is a soap or soap12 binding rather than
+// e.g. an HTTP binding.
+func (self *Binding) IsSoap() bool {
+	space := self.SoapBinding.XMLName.Space
+	return space == SoapBindingNamespace || space == Soap12BindingNamespace
+}
+
+// EnvelopeNamespace returns the namespace of the envelope matching the
+// soap version of the binding.
+func (self *Binding) EnvelopeNamespace() string {
+	if self.IsSoap12() {
+		return Soap12EnvelopeNamespace
+	}
+	return SoapEnvelopeNamespace
+}
+
+// EnvelopeNamespace returns the namespace of the envelope used by the
+// binding of the port of operation.
+func (self *Definitions) EnvelopeNamespace(operation string) (space string, err error) {
+	var bnd Binding
+	bnd, _, err = self.getBinding(operation)
+	if err != nil {
+		return
+	}
+
+	space = bnd.EnvelopeNamespace()
+	return
+}
+
+// SoapAction returns the soap action of operation. It is taken from the
+// soapAction of the binding operation and falls back to the WS-Addressing
+// action of the porttype operation's input, which is what WCF services use.
+func (self *Definitions) SoapAction(operation string) (action string, err error) {
+	var bndOp BindingOperation
+	var ptOp PortTypeOperation
+	bndOp, ptOp, err = self.getOperations(operation)
+	if err != nil {
+		return
+	}
+
+	action = bndOp.SoapOperation.SoapAction
+	if action == "" {
+		action = ptOp.Input.Action
+	}
+	return
+}
+
+// ContentType returns the HTTP Content-Type for a request of operation.
+// SOAP 1.1 requests are sent as text/xml, SOAP 1.2 requests as
+// application/soap+xml carrying the soap action as action parameter.
+func (self *Definitions) ContentType(operation string) (contentType string, err error) {
+	var bnd Binding
+	bnd, _, err = self.getBinding(operation)
+	if err != nil {
+		return
+	}
+
+	if !bnd.IsSoap12() {
+		contentType = "text/xml; charset=utf-8"
+		return
+	}
+
+	var action string
+	action, err = self.SoapAction(operation)
+	if err != nil {
+		return
+	}
+
+	params := map[string]string{"charset": "utf-8"}
+	if action != "" {
+		params["action"] = action
+	}
+
+	contentType = mime.FormatMediaType("application/soap+xml", params)
+	return
+}
+
+// Header returns the HTTP header for a request of operation, i.e. the
+// Content-Type and, for SOAP 1.1, the SOAPAction header.
+func (self *Definitions) Header(operation string) (header http.Header, err error) {
+	var bnd Binding
+	bnd, _, err = self.getBinding(operation)
+	if err != nil {
+		return
+	}
+
+	header = http.Header{}
+	var contentType string
+	contentType, err = self.ContentType(operation)
+	if err != nil {
+		return
+	}
+	header.Set("Content-Type", contentType)
+
+	if !bnd.IsSoap12() {
+		var action string
+		action, err = self.SoapAction(operation)
+		if err != nil {
+			return
+		}
+
+		// set directly to keep the casing some servers insist on
+		header["SOAPAction"] = []string{strconv.Quote(action)}
+	}
+
+	return
+}
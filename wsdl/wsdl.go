@@ -1,27 +1,37 @@
 package wsdl
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
-	"os"
+	"sort"
 	"strings"
 
 	"github.com/justwatchcom/goat/xsd"
 )
 
 type InnerDefinitions struct {
-	TargetNamespace string    `xml:"targetNamespace,attr"`
-	Types           Type      `xml:"types"`
-	Messages        []Message `xml:"message"`
-	PortType        PortType  `xml:"portType"`
-	Binding         []Binding `xml:"binding"`
-	Service         Service   `xml:"service"`
+	TargetNamespace string     `xml:"targetNamespace,attr"`
+	Imports         []Import   `xml:"import"`
+	Types           Type       `xml:"types"`
+	Messages        []Message  `xml:"message"`
+	PortTypes       []PortType `xml:"portType"`
+	Binding         []Binding  `xml:"binding"`
+	Services        []Service  `xml:"service"`
 }
 
 type Definitions struct {
 	XMLName xml.Name `xml:"definitions"`
 	Aliases map[string]string
+	// Prefixes holds the prefixes of namespaces in requests, keyed by
+	// namespace. It is initialized with the namespace declarations of the
+	// WSDL and may be changed to use other prefixes.
+	Prefixes map[string]string
+	// Strict makes WriteRequest report params which match nothing in the
+	// schema as violations, suggesting similar names of the schema. By
+	// default they fail the request as an xsd.LeftoverError only.
+	Strict bool
 	InnerDefinitions
 }
 
@@ -35,41 +45,63 @@ func (self *Definitions) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (e
 		return
 	}
 
-	self.XMLName = start.Name
-	self.Aliases = map[string]string{}
+	// Fault keeps the first fault of operations declaring several ones
+	for i := range self.PortTypes {
+		for j, op := range self.PortTypes[i].Operations {
+			if len(op.Faults) > 0 {
+				self.PortTypes[i].Operations[j].Fault = op.Faults[0]
+			}
+		}
+	}
 
-	self.Types.Schemas = xsd.SchemaMap{}
-	for _, schema := range self.Types.Schemata {
-		self.Types.Schemas[schema.TargetNamespace] = schema
+	for i := range self.Binding {
+		for j, op := range self.Binding[i].Operations {
+			if len(op.Faults) > 0 {
+				self.Binding[i].Operations[j].Fault = op.Faults[0].SoapFault
+			}
+		}
 	}
 
+	self.XMLName = start.Name
+	self.Aliases = map[string]string{}
+	self.Prefixes = map[string]string{}
+
 	for _, attr := range start.Attr {
 		if _, ok := self.Aliases[attr.Name.Local]; !ok {
 			self.Aliases[attr.Name.Local] = attr.Value
 		}
 
-		for k := range self.Types.Schemas {
-			if _, ok := self.Types.Schemas[k].Aliases[attr.Name.Local]; !ok {
-				self.Types.Schemas[k].Aliases[attr.Name.Local] = attr.Value
+		if _, ok := self.Prefixes[attr.Value]; !ok && attr.Name.Space == "xmlns" {
+			self.Prefixes[attr.Value] = attr.Name.Local
+		}
+
+		// schemas sharing a namespace are merged below, but each one keeps
+		// its own aliases
+		for i := range self.Types.Schemata {
+			if _, ok := self.Types.Schemata[i].Aliases[attr.Name.Local]; !ok {
+				self.Types.Schemata[i].Aliases[attr.Name.Local] = attr.Value
 			}
 		}
 	}
 
-	return
-}
-
-func copyMap(src map[string]interface{}) map[string]interface{} {
-	dst := map[string]interface{}{}
-	for k, v := range src {
-		dst[k] = v
+	self.Types.Schemas = xsd.SchemaMap{}
+	for _, schema := range self.Types.Schemata {
+		self.Types.Schemas.Add(schema)
 	}
-	return dst
-}
 
-func (self *Definitions) WriteRequest(operation string, w io.Writer, headerParams, bodyParams map[string]interface{}) (err error) {
-	headerParams = copyMap(headerParams)
-	bodyParams = copyMap(bodyParams)
+	return
+}
 
+// WriteRequest writes the envelope of a request of operation to w. The
+// envelope declares the prefixes of all namespaces used in the request once.
+// The body is given either by params keyed by paths like
+// "get/serviceSelector/fields" or by any other value like a struct holding
+// the content of the body element, see xsd.Params. The params are only read.
+// If the definitions are strict, params matching nothing in the schema,
+// including those below neither the header nor the body element, are
+// reported as violations. Params left over nevertheless fail the request
+// with an xsd.LeftoverError.
+func (self *Definitions) WriteRequest(operation string, w io.Writer, headerParams map[string]interface{}, body interface{}) (err error) {
 	var bndOp BindingOperation
 	var ptOp PortTypeOperation
 	bndOp, ptOp, err = self.getOperations(operation)
@@ -77,66 +109,262 @@ func (self *Definitions) WriteRequest(operation string, w io.Writer, headerParam
 		return
 	}
 
-	var header, body xsd.Schema
+	var headerSchema, bodySchema xsd.Schema
 	var headerElement, bodyElement string
-	header, headerElement, err = self.getSchema(bndOp.Input.SoapHeader.PortTypeOperationMessage)
+	if bndOp.Input.SoapHeader.Message != "" {
+		headerSchema, headerElement, err = self.getSchema(bndOp.Input.SoapHeader.PortTypeOperationMessage)
+		if err != nil {
+			return
+		}
+	}
+
+	bodySchema, bodyElement, err = self.getSchema(bndOp.Input.SoapBody.PortTypeOperationMessage, ptOp.Input)
 	if err != nil {
 		return
 	}
 
-	body, bodyElement, err = self.getSchema(bndOp.Input.SoapBody.PortTypeOperationMessage, ptOp.Input)
+	var params map[string]interface{}
+	params, err = xsd.Params(body, bodyElement)
 	if err != nil {
 		return
 	}
 
-	fmt.Fprint(w, xml.Header)
-	enc := xml.NewEncoder(io.MultiWriter(w, os.Stdout))
-	enc.Indent("", "  ")
-	defer func() {
-		if err == nil {
-			err = enc.Flush()
+	// header params are shared by all operations, even those without a
+	// header
+	if headerElement != "" {
+		var header map[string]interface{}
+		header, err = xsd.Params(headerParams, headerElement)
+		if err != nil {
+			return
+		}
+
+		for k, v := range header {
+			params[k] = v
 		}
-	}()
+	}
+
+	var space string
+	space, err = self.EnvelopeNamespace(operation)
+	if err != nil {
+		return
+	}
+
+	// the content of the envelope is encoded first, so that the envelope
+	// can declare just the namespaces used
+	content := new(bytes.Buffer)
+	enc := xsd.NewEncoder(content)
+	enc.Indent("  ", "  ")
+	enc.SetPrefixes(self.prefixes(space))
+	enc.Strict = self.Strict
 
 	envelope := xml.StartElement{
-		Name: xml.Name{
-			Space: "http://schemas.xmlsoap.org/soap/envelope/",
+		Name: enc.Name(xml.Name{
+			Space: space,
 			Local: "Envelope",
-		},
+		}),
 	}
-	enc.EncodeToken(envelope)
-	defer enc.EncodeToken(envelope.End())
 
 	soapHeader := xml.StartElement{
 		Name: xml.Name{
-			Space: "http://schemas.xmlsoap.org/soap/envelope/",
+			Space: space,
 			Local: "Header",
 		},
 	}
 	enc.EncodeToken(soapHeader)
 
-	err = header.EncodeElement(headerElement, enc, self.Types.Schemas, headerParams)
-	if err != nil {
-		return
+	if headerElement != "" {
+		err = headerSchema.EncodeElement(headerElement, enc, self.Types.Schemas, params)
+		if err != nil {
+			return
+		}
 	}
 	enc.EncodeToken(soapHeader.End())
 
 	soapBody := xml.StartElement{
 		Name: xml.Name{
-			Space: "http://schemas.xmlsoap.org/soap/envelope/",
+			Space: space,
 			Local: "Body",
 		},
 	}
 	enc.EncodeToken(soapBody)
-	err = body.EncodeElement(bodyElement, enc, self.Types.Schemas, bodyParams)
+	err = bodySchema.EncodeElement(bodyElement, enc, self.Types.Schemas, params)
 	if err != nil {
 		return
 	}
 	enc.EncodeToken(soapBody.End())
 
+	err = enc.Flush()
+	if err != nil {
+		return
+	}
+
+	roots := []string{bodyElement}
+	if headerElement != "" {
+		roots = append(roots, headerElement)
+	}
+	enc.ViolateUnmatched(params, roots...)
+
+	err = enc.Err()
+	if err != nil {
+		return
+	}
+
+	err = enc.Leftover(params)
+	if err != nil {
+		return
+	}
+
+	envelope.Attr = enc.Namespaces()
+
+	_, err = fmt.Fprint(w, xml.Header)
+	if err != nil {
+		return
+	}
+
+	out := xml.NewEncoder(w)
+	err = out.EncodeToken(envelope)
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		return
+	}
+
+	_, err = fmt.Fprintf(w, "\n%s\n", content.Bytes())
+	if err != nil {
+		return
+	}
+
+	err = out.EncodeToken(envelope.End())
+	if err == nil {
+		err = out.Flush()
+	}
+	return
+}
+
+// ReadResponse decodes the response of operation from r, which holds the
+// content of the SOAP body, against the element of the output message. The
+// element gives a map like the one of xsd.ComplexType.Decode, e.g. holding
+// "rval" of a "getResponse".
+func (self *Definitions) ReadResponse(operation string, r io.Reader) (v interface{}, err error) {
+	var bndOp BindingOperation
+	var ptOp PortTypeOperation
+	bndOp, ptOp, err = self.getOperations(operation)
+	if err != nil {
+		return
+	}
+
+	var schema xsd.Schema
+	var element string
+	schema, element, err = self.getSchema(bndOp.Output.SoapBody.PortTypeOperationMessage, ptOp.Output)
+	if err != nil {
+		return
+	}
+
+	var found bool
+	v, found, err = self.decodeElement(schema, element, r, false)
+	if err == nil && !found {
+		err = fmt.Errorf("did not find element '%s' in response", element)
+	}
+	return
+}
+
+// ReadResponseHeader decodes the header element of the output of operation,
+// e.g. a "ResponseHeader", from r, which holds the content of the SOAP
+// header, like ReadResponse. Other header elements are skipped, and v is nil
+// if the header element is missing or the output declares none.
+func (self *Definitions) ReadResponseHeader(operation string, r io.Reader) (v interface{}, err error) {
+	var bndOp BindingOperation
+	bndOp, _, err = self.getOperations(operation)
+	if err != nil {
+		return
+	}
+
+	if bndOp.Output.SoapHeader.Message == "" {
+		return
+	}
+
+	var schema xsd.Schema
+	var element string
+	schema, element, err = self.getSchema(bndOp.Output.SoapHeader.PortTypeOperationMessage)
+	if err != nil {
+		return
+	}
+
+	v, _, err = self.decodeElement(schema, element, r, true)
 	return
 }
 
+// decodeElement decodes the first top level element of r, which must be
+// element of schema unless others are skipped.
+func (self *Definitions) decodeElement(schema xsd.Schema, element string, r io.Reader, skip bool) (v interface{}, found bool, err error) {
+	dec := xsd.NewDecoder(r)
+	for {
+		var t xml.Token
+		t, err = dec.Token()
+		if err == io.EOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		start, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if start.Name.Local != element {
+			if !skip {
+				err = fmt.Errorf("have element '%s', want '%s' in response", start.Name.Local, element)
+				return
+			}
+
+			err = dec.Skip()
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		found = true
+		v, err = schema.DecodeElement(element, dec, start, self.Types.Schemas)
+		return
+	}
+}
+
+// prefixes returns the prefixes of the namespaces of a request in the
+// envelope namespace envelope. Unless the definitions declare other ones,
+// the envelope, xsi and xsd get their conventional prefixes.
+func (self *Definitions) prefixes(envelope string) map[string]string {
+	prefixes := map[string]string{}
+	set := func(space, prefix string) {
+		for s, p := range prefixes {
+			if p == prefix {
+				delete(prefixes, s)
+			}
+		}
+		prefixes[space] = prefix
+	}
+
+	set(envelope, "soapenv")
+	set(xsd.XMLSchemaInstanceNamespace, "xsi")
+	set(xsd.XMLSchemaNamespace, "xsd")
+
+	spaces := make([]string, 0, len(self.Prefixes))
+	for space := range self.Prefixes {
+		spaces = append(spaces, space)
+	}
+	sort.Strings(spaces)
+
+	for _, space := range spaces {
+		set(space, self.Prefixes[space])
+	}
+
+	return prefixes
+}
+
 func (self *Definitions) getSchema(msg ...PortTypeOperationMessage) (schema xsd.Schema, element string, err error) {
 	for _, s := range msg {
 		if s.Message == "" {
@@ -144,29 +372,31 @@ func (self *Definitions) getSchema(msg ...PortTypeOperationMessage) (schema xsd.
 		}
 
 		parts := strings.Split(s.Message, ":")
-		if len(parts) == 2 {
-			element = parts[1]
-			var ok bool
-			schema, ok = self.Types.Schemas[self.GetAlias(parts[0])]
-			if ok {
-				for _, m := range self.Messages {
-					if m.Name == element {
-						p := strings.Split(m.Part.Element, ":")
-						if len(p) != 2 {
-							err = fmt.Errorf("invalid message part element name '%s'", m.Part.Element)
-							return
-						}
-
-						element = p[1]
-						return
-					}
-				}
-
-				err = fmt.Errorf("did not find message '%s'", element)
-				return
-			}
-		} else {
+		if len(parts) != 2 {
 			err = fmt.Errorf("invalid soapheader message format '%s'", s.Message)
+			continue
+		}
+
+		m := self.message(parts[1])
+		if m == nil {
+			err = fmt.Errorf("did not find message '%s'", parts[1])
+			return
+		}
+
+		// the element is resolved in the definitions declaring the message,
+		// which may have been imported
+		var name xml.Name
+		name, err = resolve(self.messageAliases(m), m.Part.Element)
+		if err != nil || name.Space == "" {
+			err = fmt.Errorf("invalid message part element name '%s'", m.Part.Element)
+			return
+		}
+
+		var ok bool
+		schema, ok = self.Types.Schemas[name.Space]
+		if ok {
+			element = name.Local
+			return
 		}
 	}
 
@@ -174,68 +404,280 @@ func (self *Definitions) getSchema(msg ...PortTypeOperationMessage) (schema xsd.
 	return
 }
 
-func (self *Definitions) getOperations(operation string) (bndOp BindingOperation, ptOp PortTypeOperation, err error) {
-	parts := strings.Split(self.Service.Port.Binding, ":")
+// message returns the message name or nil if there is none.
+func (self *Definitions) message(name string) *Message {
+	for i := range self.Messages {
+		if self.Messages[i].Name == name {
+			return &self.Messages[i]
+		}
+	}
+
+	return nil
+}
+
+// messageAliases returns the aliases of the definitions declaring m.
+func (self *Definitions) messageAliases(m *Message) map[string]string {
+	if m.Aliases == nil {
+		return self.Aliases
+	}
+	return m.Aliases
+}
+
+// GetFault returns the name of the fault declared by the binding operation
+// whose message part is the element element, e.g. a fault detail. Both the
+// namespace and the local name of the element have to match.
+func (self *Definitions) GetFault(operation string, element xml.Name) (name string, err error) {
+	var bndOp BindingOperation
+	var ptOp PortTypeOperation
+	bndOp, ptOp, err = self.getOperations(operation)
+	if err != nil {
+		return
+	}
+
+	for _, bf := range bndOp.Faults {
+		for _, f := range ptOp.Faults {
+			if f.Name != bf.Name {
+				continue
+			}
+
+			parts := strings.Split(f.Message, ":")
+			m := self.message(parts[len(parts)-1])
+			if m == nil {
+				continue
+			}
+
+			n, e := resolve(self.messageAliases(m), m.Part.Element)
+			if e == nil && n == element {
+				name = f.Name
+				return
+			}
+		}
+	}
+
+	err = fmt.Errorf("did not find fault for element '%s' in operation '%s'", element.Local, operation)
+	return
+}
+
+// OperationOf returns the name of the operation whose input is the element
+// element, e.g. the element in the body of a request envelope.
+func (self *Definitions) OperationOf(element xml.Name) (name string, err error) {
+	for _, pt := range self.PortTypes {
+		for _, ptOp := range pt.Operations {
+			schema, local, e := self.getSchema(ptOp.Input)
+			if e == nil && local == element.Local && schema.TargetNamespace == element.Space {
+				name = ptOp.Name
+				return
+			}
+		}
+	}
+
+	err = fmt.Errorf("did not find operation of element '%s' in namespace '%s'", element.Local, element.Space)
+	return
+}
+
+// Location returns the address of the port operation is sent through.
+func (self *Definitions) Location(operation string) (location string, err error) {
+	var port ServicePort
+	port, _, _, err = self.port(operation)
+	if err != nil {
+		return
+	}
+
+	location = port.Address.Location
+	return
+}
+
+// Port returns the port operation is sent through along with the name of
+// its service, see port.
+func (self *Definitions) Port(operation string) (port ServicePort, service string, err error) {
+	port, service, _, err = self.port(operation)
+	return
+}
+
+// port returns the service port of operation along with the names of its
+// service and of the operation. The operation is either a name, or qualified by a port like
+// "port/operation", by a service like "service/operation" or by both like
+// "service/port/operation". Without a port, it is sent through the only
+// port, or else through the first SOAP port whose binding has the operation.
+func (self *Definitions) port(operation string) (port ServicePort, service, name string, err error) {
+	parts := strings.Split(operation, "/")
+	name = parts[len(parts)-1]
+
+	var portName string
 	switch len(parts) {
+	case 1:
 	case 2:
-		if self.GetAlias(parts[0]) != self.TargetNamespace {
-			err = fmt.Errorf("have '%s', want '%s' as target namespace", parts[0], self.TargetNamespace)
-			return
+		portName = parts[0]
+		for _, s := range self.Services {
+			if s.Name == parts[0] {
+				service, portName = parts[0], ""
+			}
 		}
+	case 3:
+		service, portName = parts[0], parts[1]
+	default:
+		err = fmt.Errorf("malformed operation '%s'", operation)
+		return
+	}
 
-		parts[0] = parts[1]
-		fallthrough
+	var ports []ServicePort
+	var services []string
+	for _, s := range self.Services {
+		if service != "" && s.Name != service {
+			continue
+		}
+
+		for _, p := range s.Ports {
+			if portName == "" || p.Name == portName {
+				ports = append(ports, p)
+				services = append(services, s.Name)
+			}
+		}
+	}
+
+	switch len(ports) {
+	case 0:
+		err = fmt.Errorf("did not find port of operation '%s'", operation)
+		return
 	case 1:
-		for _, bnd := range self.Binding {
-			if bnd.Name == parts[0] {
-				parts = strings.Split(bnd.Type, ":")
-				switch len(parts) {
-				case 2:
-					if self.GetAlias(parts[0]) != self.TargetNamespace {
-						err = fmt.Errorf("have '%s', want '%s' as target namespace in binding '%s'", parts[0], self.TargetNamespace, bnd.Name)
-						return
-					}
-
-					parts[0] = parts[1]
-					fallthrough
-				case 1:
-					if self.PortType.Name != parts[0] {
-						err = fmt.Errorf("have '%s', want '%s' as target namespace in binding '%s'", parts[0], self.PortType.Name, bnd.Name)
-						return
-					}
-
-					var found bool
-					for _, ptOp = range self.PortType.Operations {
-						found = ptOp.Name == operation
-						if found {
-							break
-						}
-					}
-
-					if !found {
-						err = fmt.Errorf("did not find porttype operation '%s' in binding '%s'", operation, bnd.Name)
-						return
-					}
-				default:
-					err = fmt.Errorf("malformed binding information '%s' in binding '%s'", bnd.Type, bnd.Name)
-					return
-				}
-
-				for _, bndOp = range bnd.Operations {
-					if bndOp.Name == operation {
-						return
-					}
-				}
-
-				err = fmt.Errorf("did not find operation '%s' in binding '%s'", operation, bnd.Name)
+		port, service = ports[0], services[0]
+		return
+	}
+
+	for i := range ports {
+		port, service = ports[i], services[i]
+		bnd, e := self.binding(port)
+		if e != nil || !bnd.IsSoap() {
+			continue
+		}
+
+		for _, bndOp := range bnd.Operations {
+			if bndOp.Name == name {
 				return
 			}
 		}
+	}
+
+	err = fmt.Errorf("did not find SOAP port of operation '%s'", operation)
+	return
+}
+
+// getBinding returns the binding of the port of operation along with the
+// name of the operation, see port.
+func (self *Definitions) getBinding(operation string) (bnd Binding, name string, err error) {
+	var port ServicePort
+	port, _, name, err = self.port(operation)
+	if err != nil {
+		return
+	}
+
+	bnd, err = self.binding(port)
+	return
+}
+
+// resolve returns the namespace and the local name of the qualified name
+// qname like 'tns:get', looking up its prefix in aliases. Unqualified names
+// are returned without namespace.
+func resolve(aliases map[string]string, qname string) (name xml.Name, err error) {
+	parts := strings.Split(qname, ":")
+	switch len(parts) {
+	case 1:
+		name.Local = parts[0]
+	case 2:
+		space, ok := aliases[parts[0]]
+		if !ok {
+			err = fmt.Errorf("undeclared prefix '%s' in '%s'", parts[0], qname)
+			return
+		}
 
-		err = fmt.Errorf("did not find binding '%s'", parts[0])
+		name = xml.Name{Space: space, Local: parts[1]}
 	default:
-		err = fmt.Errorf("malformed binding information: '%s'", self.Service.Port.Binding)
+		err = fmt.Errorf("malformed qualified name '%s'", qname)
+	}
+
+	return
+}
+
+// namespace returns the target namespace recorded for a merged message, port
+// type or binding, or the one of the definitions for their own ones.
+func (self *Definitions) namespace(space string) string {
+	if space == "" {
+		return self.TargetNamespace
+	}
+	return space
+}
+
+// binding returns the binding of port. The binding may be declared in any
+// of the merged definitions, it is looked up by its namespace.
+func (self *Definitions) binding(port ServicePort) (bnd Binding, err error) {
+	var name xml.Name
+	name, err = resolve(self.Aliases, port.Binding)
+	if err != nil {
+		err = fmt.Errorf("malformed binding information: %s", err)
+		return
+	}
+
+	for _, bnd = range self.Binding {
+		if bnd.Name == name.Local && (name.Space == "" || self.namespace(bnd.TargetNamespace) == name.Space) {
+			return
+		}
+	}
+
+	err = fmt.Errorf("did not find binding '%s' in namespace '%s'", name.Local, name.Space)
+	return
+}
+
+func (self *Definitions) getOperations(operation string) (bndOp BindingOperation, ptOp PortTypeOperation, err error) {
+	var bnd Binding
+	bnd, operation, err = self.getBinding(operation)
+	if err != nil {
+		return
+	}
+
+	aliases := bnd.Aliases
+	if aliases == nil {
+		aliases = self.Aliases
+	}
+
+	// the port type may be declared in other definitions than the binding
+	var name xml.Name
+	name, err = resolve(aliases, bnd.Type)
+	if err != nil {
+		err = fmt.Errorf("malformed binding information in binding '%s': %s", bnd.Name, err)
+		return
+	}
+
+	var pt *PortType
+	for i := range self.PortTypes {
+		if self.PortTypes[i].Name == name.Local && (name.Space == "" || self.namespace(self.PortTypes[i].TargetNamespace) == name.Space) {
+			pt = &self.PortTypes[i]
+		}
+	}
+
+	if pt == nil {
+		err = fmt.Errorf("did not find porttype '%s' in namespace '%s' of binding '%s'", name.Local, name.Space, bnd.Name)
+		return
+	}
+
+	var found bool
+	for _, ptOp = range pt.Operations {
+		found = ptOp.Name == operation
+		if found {
+			break
+		}
+	}
+
+	if !found {
+		err = fmt.Errorf("did not find porttype operation '%s' in binding '%s'", operation, bnd.Name)
+		return
+	}
+
+	for _, bndOp = range bnd.Operations {
+		if bndOp.Name == operation {
+			return
+		}
 	}
 
+	err = fmt.Errorf("did not find operation '%s' in binding '%s'", operation, bnd.Name)
 	return
 }
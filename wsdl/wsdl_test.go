@@ -0,0 +1,287 @@
+package wsdl
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/justwatchcom/goat/xsd"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const serviceWSDL = `<definitions xmlns="http://schemas.xmlsoap.org/wsdl/" xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:service" targetNamespace="urn:service">
+  <types>
+    <xsd:schema targetNamespace="urn:service" elementFormDefault="qualified">
+      <xsd:element name="ResponseHeader">
+        <xsd:complexType><xsd:sequence>
+          <xsd:element name="requestId" type="xsd:string"/>
+          <xsd:element name="operations" type="xsd:long"/>
+          <xsd:element name="responseTime" type="xsd:long"/>
+        </xsd:sequence></xsd:complexType>
+      </xsd:element>
+      <xsd:element name="get">
+        <xsd:complexType><xsd:sequence><xsd:element name="id" type="xsd:int"/></xsd:sequence></xsd:complexType>
+      </xsd:element>
+      <xsd:element name="getResponse">
+        <xsd:complexType><xsd:sequence><xsd:element name="rval" type="xsd:string" maxOccurs="unbounded"/></xsd:sequence></xsd:complexType>
+      </xsd:element>
+    </xsd:schema>
+  </types>
+  <message name="getRequest"><part name="parameters" element="tns:get"/></message>
+  <message name="getResponse"><part name="parameters" element="tns:getResponse"/></message>
+  <message name="ResponseHeader"><part name="ResponseHeader" element="tns:ResponseHeader"/></message>
+  <portType name="ServicePort">
+    <operation name="get">
+      <input message="tns:getRequest"/>
+      <output message="tns:getResponse"/>
+    </operation>
+  </portType>
+  <binding name="ServiceBinding" type="tns:ServicePort">
+    <soap:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <operation name="get">
+      <soap:operation soapAction=""/>
+      <input><soap:body use="literal"/></input>
+      <output><soap:header message="tns:ResponseHeader" part="ResponseHeader" use="literal"/><soap:body use="literal"/></output>
+    </operation>
+  </binding>
+  <service name="Service">
+    <port name="ServicePort" binding="tns:ServiceBinding"><soap:address location="http://localhost/service"/></port>
+  </service>
+</definitions>`
+
+func TestDefinitions_ReadResponse(t *testing.T) {
+	Convey("given definitions and a response", t, func() {
+		d := new(Definitions)
+		err := xml.Unmarshal([]byte(serviceWSDL), d)
+		So(err, ShouldBeNil)
+
+		header, err := d.ReadResponseHeader("get", strings.NewReader(`<Other xmlns="urn:other"/>
+<ResponseHeader xmlns="urn:service"><requestId>abc</requestId><operations>2</operations><responseTime>31</responseTime></ResponseHeader>`))
+		So(err, ShouldBeNil)
+		So(fmt.Sprint(header), ShouldEqual, "map[operations:2 requestId:abc responseTime:31]")
+
+		body, err := d.ReadResponse("get", strings.NewReader(`<getResponse xmlns="urn:service"><rval>a</rval></getResponse>`))
+		So(err, ShouldBeNil)
+		So(fmt.Sprint(body), ShouldEqual, "map[rval:[a]]")
+
+		header, err = d.ReadResponseHeader("get", strings.NewReader(""))
+		So(err, ShouldBeNil)
+		So(header, ShouldBeNil)
+
+		Convey("test case 'output without header'", func() {
+			d := new(Definitions)
+			err := xml.Unmarshal([]byte(versionsWSDL), d)
+			So(err, ShouldBeNil)
+
+			header, err := d.ReadResponseHeader("get", strings.NewReader(`<ResponseHeader xmlns="urn:service"><requestId>abc</requestId></ResponseHeader>`))
+			So(err, ShouldBeNil)
+			So(header, ShouldBeNil)
+		})
+	})
+}
+
+const portsWSDL = `<definitions xmlns="http://schemas.xmlsoap.org/wsdl/" xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/" xmlns:soap12="http://schemas.xmlsoap.org/wsdl/soap12/" xmlns:http="http://schemas.xmlsoap.org/wsdl/http/" xmlns:tns="urn:service" targetNamespace="urn:service">
+  <portType name="ServicePort"><operation name="get"/></portType>
+  <portType name="AdminPort"><operation name="reset"/></portType>
+  <binding name="ServiceHttp" type="tns:ServicePort">
+    <http:binding verb="GET"/>
+    <operation name="get"/>
+  </binding>
+  <binding name="ServiceSoap" type="tns:ServicePort">
+    <soap:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <operation name="get"><soap:operation soapAction="urn:get"/></operation>
+  </binding>
+  <binding name="ServiceSoap12" type="tns:ServicePort">
+    <soap12:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <operation name="get"><soap12:operation soapAction="urn:get"/></operation>
+  </binding>
+  <binding name="AdminSoap" type="tns:AdminPort">
+    <soap:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <operation name="reset"><soap:operation soapAction="urn:reset"/></operation>
+  </binding>
+  <service name="Service">
+    <port name="ServiceHttp" binding="tns:ServiceHttp"><http:address location="http://localhost/http"/></port>
+    <port name="ServiceSoap" binding="tns:ServiceSoap"><soap:address location="http://localhost/soap"/></port>
+    <port name="ServiceSoap12" binding="tns:ServiceSoap12"><soap12:address location="http://localhost/soap12"/></port>
+  </service>
+  <service name="Admin">
+    <port name="AdminSoap" binding="tns:AdminSoap"><soap:address location="http://localhost/admin"/></port>
+  </service>
+</definitions>`
+
+type portTestCase struct {
+	operation, expectedLocation, expectedEnvelope string
+	isFaulty                                      bool
+}
+
+var portTestCases = []portTestCase{
+	{"get", "http://localhost/soap", SoapEnvelopeNamespace, false},
+	{"Service/get", "http://localhost/soap", SoapEnvelopeNamespace, false},
+	{"ServiceSoap12/get", "http://localhost/soap12", Soap12EnvelopeNamespace, false},
+	{"Service/ServiceSoap12/get", "http://localhost/soap12", Soap12EnvelopeNamespace, false},
+	{"reset", "http://localhost/admin", SoapEnvelopeNamespace, false},
+	{"Admin/reset", "http://localhost/admin", SoapEnvelopeNamespace, false},
+	{"Admin/ServiceSoap/get", "", "", true},
+	{"Other/get", "", "", true},
+}
+
+func TestDefinitions_Ports(t *testing.T) {
+	Convey("given definitions with several services and ports", t, func() {
+		d := new(Definitions)
+		err := xml.Unmarshal([]byte(portsWSDL), d)
+		So(err, ShouldBeNil)
+		So(len(d.PortTypes), ShouldEqual, 2)
+		So(len(d.Services), ShouldEqual, 2)
+
+		for _, c := range portTestCases {
+			Convey(fmt.Sprintf("test case '%s'", c.operation), func() {
+				location, err := d.Location(c.operation)
+				if c.isFaulty {
+					So(err, ShouldNotBeNil)
+					return
+				}
+				So(err, ShouldBeNil)
+				So(location, ShouldEqual, c.expectedLocation)
+
+				space, err := d.EnvelopeNamespace(c.operation)
+				So(err, ShouldBeNil)
+				So(space, ShouldEqual, c.expectedEnvelope)
+
+				_, err = d.SoapAction(c.operation)
+				So(err, ShouldBeNil)
+			})
+		}
+	})
+}
+
+const versionsWSDL = `<definitions xmlns="http://schemas.xmlsoap.org/wsdl/" xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/" xmlns:soap12="http://schemas.xmlsoap.org/wsdl/soap12/" xmlns:wsaw="http://www.w3.org/2006/05/addressing/wsdl" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:service" targetNamespace="urn:service">
+  <types>
+    <xsd:schema targetNamespace="urn:service" elementFormDefault="qualified">
+      <xsd:element name="get">
+        <xsd:complexType><xsd:sequence><xsd:element name="id" type="xsd:int"/></xsd:sequence></xsd:complexType>
+      </xsd:element>
+    </xsd:schema>
+  </types>
+  <message name="getRequest"><part name="parameters" element="tns:get"/></message>
+  <portType name="ServicePort">
+    <operation name="get"><input message="tns:getRequest" wsaw:Action="urn:service/get"/></operation>
+  </portType>
+  <binding name="ServiceSoap" type="tns:ServicePort">
+    <soap:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <operation name="get"><soap:operation soapAction="urn:get"/><input><soap:body use="literal"/></input></operation>
+  </binding>
+  <binding name="ServiceSoap12" type="tns:ServicePort">
+    <soap12:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <operation name="get"><soap12:operation soapAction="urn:get"/><input><soap12:body use="literal"/></input></operation>
+  </binding>
+  <binding name="ServiceSoap12Addressing" type="tns:ServicePort">
+    <soap12:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <operation name="get"><soap12:operation/><input><soap12:body use="literal"/></input></operation>
+  </binding>
+  <service name="Service">
+    <port name="ServiceSoap" binding="tns:ServiceSoap"><soap:address location="http://localhost/soap"/></port>
+    <port name="ServiceSoap12" binding="tns:ServiceSoap12"><soap12:address location="http://localhost/soap12"/></port>
+    <port name="ServiceSoap12Addressing" binding="tns:ServiceSoap12Addressing"><soap12:address location="http://localhost/addressing"/></port>
+  </service>
+</definitions>`
+
+type envelopeTestCase struct {
+	operation, expectedEnvelope string
+}
+
+var envelopeTestCases = []envelopeTestCase{
+	{"ServiceSoap/get", `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:tns="urn:service">`},
+	{"ServiceSoap12/get", `<soapenv:Envelope xmlns:soapenv="http://www.w3.org/2003/05/soap-envelope" xmlns:tns="urn:service">`},
+}
+
+func TestDefinitions_WriteRequest(t *testing.T) {
+	Convey("given definitions with SOAP 1.1 and 1.2 bindings", t, func() {
+		d := new(Definitions)
+		err := xml.Unmarshal([]byte(versionsWSDL), d)
+		So(err, ShouldBeNil)
+
+		for _, c := range envelopeTestCases {
+			Convey(fmt.Sprintf("test case '%s'", c.operation), func() {
+				var b strings.Builder
+				err := d.WriteRequest(c.operation, &b, nil, map[string]interface{}{"get/id": 1})
+				So(err, ShouldBeNil)
+				So(b.String(), ShouldContainSubstring, c.expectedEnvelope)
+				So(b.String(), ShouldContainSubstring, "<soapenv:Body>\n    <tns:get>")
+				So(b.String(), ShouldContainSubstring, "</soapenv:Envelope>")
+			})
+		}
+
+		Convey("test case 'misspelled root'", func() {
+			d.Strict = true
+			defer func() { d.Strict = false }()
+
+			var b strings.Builder
+			err := d.WriteRequest("get", &b, nil, map[string]interface{}{"get/id": 1, "gett/id": 2, "other/id": 3})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, `invalid parameters: 'gett/id': matches nothing in the schema, did you mean 'get/id'?; 'other/id': matches nothing in the schema`)
+		})
+
+		Convey("test case 'leftover params'", func() {
+
+			var b strings.Builder
+			err := d.WriteRequest("get", &b, nil, map[string]interface{}{"get/id": 1, "gett/id": 2})
+			So(err, ShouldNotBeNil)
+
+			var leftover xsd.LeftoverError
+			So(errors.As(err, &leftover), ShouldBeTrue)
+			So([]string(leftover), ShouldResemble, []string{"gett/id"})
+		})
+
+		Convey("test case 'failing writer'", func() {
+			err := d.WriteRequest("get", failingWriter{}, nil, map[string]interface{}{"get/id": 1})
+			So(err, ShouldEqual, errWrite)
+		})
+	})
+}
+
+var errWrite = errors.New("write failed")
+
+// failingWriter fails every write like a writer whose context is done.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (n int, err error) {
+	return 0, errWrite
+}
+
+type headerTestCase struct {
+	operation, expectedAction, expectedContentType string
+	expectedSoapAction                             []string
+}
+
+var headerTestCases = []headerTestCase{
+	{"ServiceSoap/get", "urn:get", "text/xml; charset=utf-8", []string{`"urn:get"`}},
+	{"ServiceSoap12/get", "urn:get", `application/soap+xml; action="urn:get"; charset=utf-8`, nil},
+	{"ServiceSoap12Addressing/get", "urn:service/get", `application/soap+xml; action="urn:service/get"; charset=utf-8`, nil},
+}
+
+func TestDefinitions_Header(t *testing.T) {
+	Convey("given definitions with SOAP 1.1 and 1.2 bindings", t, func() {
+		d := new(Definitions)
+		err := xml.Unmarshal([]byte(versionsWSDL), d)
+		So(err, ShouldBeNil)
+
+		for _, c := range headerTestCases {
+			Convey(fmt.Sprintf("test case '%s'", c.operation), func() {
+				action, err := d.SoapAction(c.operation)
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, c.expectedAction)
+
+				contentType, err := d.ContentType(c.operation)
+				So(err, ShouldBeNil)
+				So(contentType, ShouldEqual, c.expectedContentType)
+
+				header, err := d.Header(c.operation)
+				So(err, ShouldBeNil)
+				So(header.Get("Content-Type"), ShouldEqual, c.expectedContentType)
+				So(header["SOAPAction"], ShouldResemble, c.expectedSoapAction)
+			})
+		}
+	})
+}
@@ -0,0 +1,314 @@
+package xsd
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Attribute struct {
+	XMLName    xml.Name    `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	Name       string      `xml:"name,attr"`
+	Ref        string      `xml:"ref,attr"`
+	Type       string      `xml:"type,attr"`
+	Use        string      `xml:"use,attr"`
+	Default    string      `xml:"default,attr"`
+	Fixed      string      `xml:"fixed,attr"`
+	Form       string      `xml:"form,attr"`
+	SimpleType *SimpleType `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+	// doc is the included document declaring a global attribute merged into
+	// another schema, see Schema.Merge.
+	doc *Schema
+}
+
+type AttributeGroup struct {
+	XMLName         xml.Name         `xml:"http://www.w3.org/2001/XMLSchema attributeGroup"`
+	Name            string           `xml:"name,attr"`
+	Ref             string           `xml:"ref,attr"`
+	Attributes      []Attribute      `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	AttributeGroups []AttributeGroup `xml:"http://www.w3.org/2001/XMLSchema attributeGroup"`
+	// doc is the included document declaring a global attribute group merged into
+	// another schema, see Schema.Merge.
+	doc *Schema
+}
+
+// AttributePath returns the param path of the attribute name of the element
+// at path, e.g. "get/selector/@id".
+func AttributePath(path []string, name string) string {
+	return MakePath(append(path[:len(path):len(path)], "@"+name))
+}
+
+// lookupSchema returns the schema and the local name of the qualified name
+// qname like 'tns:Type'.
+func lookupSchema(qname string, sr SchemaRepository, ga GetAliaser) (schema *Schema, local string, err error) {
+	parts := strings.Split(qname, ":")
+	if len(parts) != 2 {
+		err = fmt.Errorf("malformed qualified name '%s'", qname)
+		return
+	}
+
+	var s Schemaer
+	s, err = sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return
+	}
+
+	var ok bool
+	schema, ok = s.(*Schema)
+	if !ok {
+		err = fmt.Errorf("namespace '%s' of '%s' does not declare attributes", ga.GetAlias(parts[0]), qname)
+		return
+	}
+
+	local = parts[1]
+	return
+}
+
+func encodeAttributes(attrs []Attribute, groups []AttributeGroup, enc *Encoder, start *xml.StartElement, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
+	for _, a := range attrs {
+		err = a.Encode(enc, start, sr, ga, params, path...)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, g := range groups {
+		err = g.Encode(enc, start, sr, ga, params, path...)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (self *AttributeGroup) Encode(enc *Encoder, start *xml.StartElement, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
+	if self.Ref == "" {
+		err = encodeAttributes(self.Attributes, self.AttributeGroups, enc, start, sr, ga, params, path...)
+		return
+	}
+
+	var schema *Schema
+	var name string
+	schema, name, err = lookupSchema(self.Ref, sr, ga)
+	if err != nil {
+		return
+	}
+
+	for _, g := range schema.AttributeGroups {
+		if g.Name == name {
+			err = g.Encode(enc, start, sr, schema.scope(g.doc), params, path...)
+			return
+		}
+	}
+
+	err = fmt.Errorf("did not find attribute group '%s' in path %q", self.Ref, path)
+	return
+}
+
+// Encode adds the attribute to start if its param, e.g. "get/selector/@id",
+// is given. Fixed values are added even without a param. Missing required
+// attributes, prohibited ones, wrong fixed values and values violating
+// facets are recorded as violations of enc.
+func (self *Attribute) Encode(enc *Encoder, start *xml.StartElement, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
+	if self.Ref != "" {
+		var schema *Schema
+		var name string
+		schema, name, err = lookupSchema(self.Ref, sr, ga)
+		if err != nil {
+			return
+		}
+
+		for _, a := range schema.Attributes {
+			if a.Name == name {
+				// global attributes are always qualified
+				a.Form = "qualified"
+				if self.Use != "" {
+					a.Use = self.Use
+				}
+				if self.Fixed != "" {
+					a.Fixed = self.Fixed
+				}
+				if self.Default != "" {
+					a.Default = self.Default
+				}
+
+				err = a.Encode(enc, start, sr, schema.scope(a.doc), params, path...)
+				return
+			}
+		}
+
+		err = fmt.Errorf("did not find attribute '%s' in path %q", self.Ref, path)
+		return
+	}
+
+	key := AttributePath(path, self.Name)
+	var v interface{}
+	var ok bool
+	if self.isList(sr, ga) && !enc.limit {
+		v, ok = enc.take(params, key)
+	} else {
+		v, ok = enc.value(params, key)
+	}
+	if !ok {
+		switch {
+		case self.Fixed != "":
+			start.Attr = append(start.Attr, self.attr(ga, self.Fixed))
+		case self.Use == "required":
+			enc.Violate(key, "missing required attribute '%s'", self.Name)
+		}
+		return
+	}
+
+	if self.Use == "prohibited" {
+		enc.Violate(key, "prohibited attribute '%s' must not be set", self.Name)
+		return
+	}
+
+	var value string
+	switch {
+	case self.Type != "":
+		parts := strings.Split(self.Type, ":")
+		if len(parts) != 2 {
+			err = fmt.Errorf("malformed type '%s' of attribute '%s'", self.Type, key)
+			return
+		}
+
+		var schema Schemaer
+		schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
+		if err != nil {
+			return
+		}
+
+		value, err = schema.FormatType(parts[1], sr, v)
+	case self.SimpleType != nil:
+		value, err = self.SimpleType.Format(sr, ga, v)
+	default:
+		value = fmt.Sprint(v)
+	}
+
+	var facetErr *FacetError
+	if errors.As(err, &facetErr) {
+		enc.Violate(key, "%s", facetErr)
+		err = nil
+		return
+	}
+
+	if err != nil {
+		err = fmt.Errorf("attribute '%s': %s", key, err)
+		return
+	}
+
+	if self.Fixed != "" && value != self.Fixed {
+		enc.Violate(key, "have '%s', want fixed value '%s'", value, self.Fixed)
+		return
+	}
+
+	start.Attr = append(start.Attr, self.attr(ga, value))
+	return
+}
+
+// isList reports whether the attribute has a list type, whose slices give
+// a single value rather than one per occurrence.
+func (self *Attribute) isList(sr SchemaRepository, ga GetAliaser) bool {
+	st := self.SimpleType
+	if st == nil && self.Type != "" {
+		schema, name, err := lookupSchema(self.Type, sr, ga)
+		if err != nil {
+			return false
+		}
+
+		for i := range schema.SimpleTypes {
+			if schema.SimpleTypes[i].Name == name {
+				st, ga = &schema.SimpleTypes[i], schema.scope(schema.SimpleTypes[i].doc)
+			}
+		}
+	}
+
+	if st == nil {
+		return false
+	}
+
+	primitive, err := st.primitive(sr, ga)
+	return err == nil && primitive == "list"
+}
+
+// parse returns the value of s for the type of the attribute.
+func (self *Attribute) parse(sr SchemaRepository, ga GetAliaser, s string) (v interface{}, err error) {
+	switch {
+	case self.Type != "":
+		v, err = parseType(self.Type, sr, ga, s)
+	case self.SimpleType != nil:
+		v, err = self.SimpleType.Parse(sr, ga, s)
+	default:
+		v = s
+	}
+
+	if err != nil {
+		err = fmt.Errorf("attribute '%s': %s", self.Name, err)
+	}
+	return
+}
+
+// declareAttributes adds the attributes and those of the attribute groups
+// to decls, following references.
+func declareAttributes(attrs []Attribute, groups []AttributeGroup, sr SchemaRepository, ga GetAliaser, decls map[string]attributeDecl) (err error) {
+	for i := range attrs {
+		a := &attrs[i]
+		if a.Ref == "" {
+			decls[a.Name] = attributeDecl{a, ga}
+			continue
+		}
+
+		var schema *Schema
+		var name string
+		schema, name, err = lookupSchema(a.Ref, sr, ga)
+		if err != nil {
+			return
+		}
+
+		for j := range schema.Attributes {
+			if schema.Attributes[j].Name == name {
+				decls[name] = attributeDecl{&schema.Attributes[j], schema.scope(schema.Attributes[j].doc)}
+			}
+		}
+	}
+
+	for _, g := range groups {
+		if g.Ref == "" {
+			err = declareAttributes(g.Attributes, g.AttributeGroups, sr, ga, decls)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		var schema *Schema
+		var name string
+		schema, name, err = lookupSchema(g.Ref, sr, ga)
+		if err != nil {
+			return
+		}
+
+		for _, sg := range schema.AttributeGroups {
+			if sg.Name == name {
+				err = declareAttributes(sg.Attributes, sg.AttributeGroups, sr, schema.scope(sg.doc), decls)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func (self *Attribute) attr(ga GetAliaser, value string) xml.Attr {
+	name := xml.Name{Local: self.Name}
+	if ga.AttributeQualified(self.Form) {
+		name.Space = ga.Namespace()
+	}
+
+	return xml.Attr{Name: name, Value: value}
+}
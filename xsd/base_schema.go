@@ -2,38 +2,60 @@ package xsd
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"reflect"
 )
 
 type mapping struct {
 	xsdSchema []string
-	kinds     []reflect.Kind
-	format    string
+	format    func(name string, v interface{}) (string, error)
 }
 
 // These mappings are used to map between a xsd type which has a base like
-// '<restriction base="string"/>'.
+// '<restriction base="string"/>'. The format functions produce the
+// canonical lexical form of a Go value, or errNoMapping if they do not
+// support its type.
 var mappings = []mapping{
 	{
 		xsdSchema: []string{"boolean"},
-		kinds:     []reflect.Kind{reflect.Bool},
-		format:    "%t",
+		format:    formatBoolean,
 	},
 	{
-		xsdSchema: []string{"int", "long"},
-		kinds:     []reflect.Kind{reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr},
-		format:    "%d",
+		xsdSchema: []string{
+			"integer", "long", "int", "short", "byte",
+			"nonNegativeInteger", "positiveInteger", "nonPositiveInteger", "negativeInteger",
+			"unsignedLong", "unsignedInt", "unsignedShort", "unsignedByte",
+		},
+		format: formatInteger,
 	},
 	{
-		xsdSchema: []string{"float"},
-		kinds:     []reflect.Kind{reflect.Float32, reflect.Float64},
-		format:    "%f",
+		xsdSchema: []string{"decimal"},
+		format:    formatDecimal,
 	},
 	{
-		xsdSchema: []string{"string"},
-		kinds:     []reflect.Kind{reflect.String},
-		format:    "%s",
+		xsdSchema: []string{"float", "double"},
+		format:    formatFloat,
+	},
+	{
+		xsdSchema: []string{"dateTime", "date", "time", "gYearMonth", "gYear", "gMonthDay", "gDay", "gMonth"},
+		format:    formatTime,
+	},
+	{
+		xsdSchema: []string{"duration"},
+		format:    formatDuration,
+	},
+	{
+		xsdSchema: []string{"base64Binary", "hexBinary"},
+		format:    formatBinary,
+	},
+	{
+		xsdSchema: []string{
+			"string", "normalizedString", "token", "language", "Name", "NCName",
+			"ID", "IDREF", "IDREFS", "ENTITY", "ENTITIES", "NMTOKEN", "NMTOKENS",
+			"anyURI", "QName", "NOTATION", "anySimpleType",
+		},
+		format: formatString,
 	},
 }
 
@@ -44,69 +66,89 @@ var mappings = []mapping{
 type baseSchema struct{}
 
 // http://www.w3.org/2001/XMLSchema-datatypes does not have elements.
-func (baseSchema) EncodeElement(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) error {
+func (baseSchema) EncodeElement(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) error {
 	return fmt.Errorf("not implemented")
 }
 
-func (baseSchema) EncodeType(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) (err error) {
-	v, ok := params[MakePath(path)]
+func (baseSchema) EncodeType(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) (err error) {
+	v, ok := enc.value(params, MakePath(path))
 	if !ok {
 		err = fmt.Errorf("did not find data '%s'", MakePath(path))
 		return
 	}
 
-	var del bool
-	var newVal interface{}
-	del, newVal, err = encodeInterfaceType(name, enc, v)
+	var s string
+	s, err = formatValue(name, v)
 	if err != nil {
 		return
 	}
 
-	if newVal != nil {
-		params[MakePath(path)] = newVal
-	}
-
-	if del {
-		delete(params, MakePath(path))
-	}
+	err = enc.EncodeToken(xml.CharData(s))
 	return
 }
 
-func encodeInterfaceType(name string, enc *xml.Encoder, v interface{}) (del bool, newVal interface{}, err error) {
-	val := reflect.ValueOf(v)
-	if val.Kind() == reflect.Slice {
-		if val.Len() == 0 {
-			del = true
-			return
-		}
+// simple types of http://www.w3.org/2001/XMLSchema have no attributes.
+func (baseSchema) EncodeAttributes(name string, enc *Encoder, start *xml.StartElement, sr SchemaRepository, params map[string]interface{}, path ...string) error {
+	return nil
+}
 
-		var d bool
-		if d, newVal, err = encodeInterfaceType(name, enc, val.Index(0).Interface()); err != nil {
-			return
-		} else if d {
-			newVal = val.Slice(1, val.Len()).Interface()
-		}
+func (baseSchema) FormatType(name string, sr SchemaRepository, v interface{}) (string, error) {
+	return formatValue(name, v)
+}
 
-		if reflect.ValueOf(newVal).Len() == 0 {
-			del = true
-		}
+func formatValue(name string, v interface{}) (s string, err error) {
+	if v == Nil {
+		err = errors.New("xsd.Nil is only allowed for nillable elements")
 		return
 	}
 
+	// dereference pointers like *string, but keep *big.Int and friends as
+	// well as pointers to types whose MarshalText has a pointer receiver
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Ptr && !val.IsNil() && !isBig(v) {
+		e := val.Elem().Interface()
+		if isTextMarshaler(v) && !isTextMarshaler(e) {
+			break
+		}
+
+		val = val.Elem()
+		v = e
+	}
+
 	for _, m := range mappings {
 		for _, n := range m.xsdSchema {
 			if n == name {
-				for _, t := range m.kinds {
-					if t == val.Kind() {
-						del = true
-						err = enc.EncodeToken(xml.CharData(fmt.Sprintf(m.format, v)))
-						return
-					}
+				s, err = m.format(name, v)
+				if err == errNoMapping {
+					err = fmt.Errorf("no mapping found for xsd base type %s and %T", name, v)
 				}
+				return
 			}
 		}
 	}
 
-	err = fmt.Errorf("no mapping found for xsd base type %s and kind %s", name, val.Kind())
+	err = fmt.Errorf("no mapping found for xsd base type %s and %T", name, v)
 	return
 }
+
+// http://www.w3.org/2001/XMLSchema-datatypes does not have elements.
+func (baseSchema) DecodeElement(name string, dec *Decoder, start xml.StartElement, sr SchemaRepository) (interface{}, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (baseSchema) DecodeType(name string, dec *Decoder, start xml.StartElement, sr SchemaRepository) (interface{}, error) {
+	if name == "anyType" {
+		return dec.decodeAny(start)
+	}
+
+	s, err := dec.text(start)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseValue(name, s)
+}
+
+func (baseSchema) ParseType(name string, sr SchemaRepository, s string) (interface{}, error) {
+	return parseValue(name, s)
+}
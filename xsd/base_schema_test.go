@@ -2,9 +2,12 @@ package xsd
 
 import (
 	"bytes"
-	"encoding/xml"
 	"fmt"
+	"math"
+	"math/big"
+	"net"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -62,7 +65,121 @@ var (
 			},
 			expectedResult: "1",
 		},
+		{
+			comment:        "double encoding keeps precision",
+			name:           "double",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": 123456789.123456789},
+			expectedResult: "1.2345678912345679E8",
+		},
+		{
+			comment:        "double encoding of an integral value",
+			name:           "double",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": 100},
+			expectedResult: "1.0E2",
+		},
+		{
+			comment:        "float encoding of a negative exponent",
+			name:           "float",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": float32(-0.00125)},
+			expectedResult: "-1.25E-3",
+		},
+		{
+			comment:        "double encoding of zero",
+			name:           "double",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": 0.0},
+			expectedResult: "0.0E0",
+		},
+		{
+			comment:        "float encoding",
+			name:           "float",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": math.Inf(-1)},
+			expectedResult: "-INF",
+		},
+		{
+			comment:        "decimal encoding of a big.Rat",
+			name:           "decimal",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": big.NewRat(-1234567, 1000)},
+			expectedResult: "-1234.567",
+		},
+		{
+			comment:  "decimal encoding of a big.Rat without finite representation",
+			name:     "decimal",
+			path:     []string{"simple_path"},
+			params:   map[string]interface{}{"simple_path": big.NewRat(1, 3)},
+			isFaulty: true,
+		},
+		{
+			comment:        "unsignedByte encoding",
+			name:           "unsignedByte",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": uint8(255)},
+			expectedResult: "255",
+		},
+		{
+			comment:  "positiveInteger encoding out of range",
+			name:     "positiveInteger",
+			path:     []string{"simple_path"},
+			params:   map[string]interface{}{"simple_path": 0},
+			isFaulty: true,
+		},
+		{
+			comment:        "dateTime encoding",
+			name:           "dateTime",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": time.Date(2016, 1, 2, 3, 4, 5, 600000000, time.UTC)},
+			expectedResult: "2016-01-02T03:04:05.6Z",
+		},
+		{
+			comment:        "date encoding of a pointer",
+			name:           "date",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": &testDate},
+			expectedResult: "2016-01-02",
+		},
+		{
+			comment:        "duration encoding",
+			name:           "duration",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": -(90*time.Minute + 1500*time.Millisecond)},
+			expectedResult: "-PT1H30M1.5S",
+		},
+		{
+			comment:        "base64Binary encoding",
+			name:           "base64Binary",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": []byte("goat")},
+			expectedResult: "Z29hdA==",
+		},
+		{
+			comment:        "hexBinary encoding",
+			name:           "hexBinary",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": []byte{0xca, 0xfe}},
+			expectedResult: "CAFE",
+		},
+		{
+			comment:        "token encoding collapses white space",
+			name:           "token",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": "  a\tb \n c "},
+			expectedResult: "a b c",
+		},
+		{
+			comment:        "anyURI encoding of a TextMarshaler",
+			name:           "anyURI",
+			path:           []string{"simple_path"},
+			params:         map[string]interface{}{"simple_path": net.ParseIP("127.0.0.1")},
+			expectedResult: "127.0.0.1",
+		},
 	}
+
+	testDate = time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
 )
 
 func TestBaseSchema_EncodeType(t *testing.T) {
@@ -72,7 +189,7 @@ func TestBaseSchema_EncodeType(t *testing.T) {
 		for _, c := range testCases {
 			Convey(fmt.Sprintf("test case '%s'", c.comment), func() {
 				buf := new(bytes.Buffer)
-				enc := xml.NewEncoder(buf)
+				enc := NewEncoder(buf)
 				err := bs.EncodeType(c.name, enc, sr, c.params, c.path...)
 				if c.isFaulty {
 					So(err, ShouldNotBeNil)
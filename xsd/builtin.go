@@ -0,0 +1,438 @@
+package xsd
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errNoMapping = errors.New("no mapping")
+
+// integerRanges holds the value space of the builtin integer types, where
+// a nil bound is unbounded.
+var integerRanges = map[string][2]*big.Int{
+	"integer":            {nil, nil},
+	"long":               {big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)},
+	"int":                {big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)},
+	"short":              {big.NewInt(math.MinInt16), big.NewInt(math.MaxInt16)},
+	"byte":               {big.NewInt(math.MinInt8), big.NewInt(math.MaxInt8)},
+	"nonNegativeInteger": {big.NewInt(0), nil},
+	"positiveInteger":    {big.NewInt(1), nil},
+	"nonPositiveInteger": {nil, big.NewInt(0)},
+	"negativeInteger":    {nil, big.NewInt(-1)},
+	"unsignedLong":       {big.NewInt(0), new(big.Int).SetUint64(math.MaxUint64)},
+	"unsignedInt":        {big.NewInt(0), big.NewInt(math.MaxUint32)},
+	"unsignedShort":      {big.NewInt(0), big.NewInt(math.MaxUint16)},
+	"unsignedByte":       {big.NewInt(0), big.NewInt(math.MaxUint8)},
+}
+
+func isTextMarshaler(v interface{}) bool {
+	_, ok := v.(encoding.TextMarshaler)
+	return ok
+}
+
+func isBig(v interface{}) bool {
+	switch v.(type) {
+	case *big.Int, *big.Rat, *big.Float:
+		return true
+	}
+	return false
+}
+
+// marshalText returns the text of an encoding.TextMarshaler.
+func marshalText(v interface{}) (s string, err error) {
+	m, ok := v.(encoding.TextMarshaler)
+	if !ok {
+		err = errNoMapping
+		return
+	}
+
+	var b []byte
+	b, err = m.MarshalText()
+	s = string(b)
+	return
+}
+
+func formatBoolean(name string, v interface{}) (s string, err error) {
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Bool:
+		s = strconv.FormatBool(val.Bool())
+	case reflect.String:
+		switch val.String() {
+		case "true", "1":
+			s = "true"
+		case "false", "0":
+			s = "false"
+		default:
+			err = fmt.Errorf("invalid boolean '%s'", val.String())
+		}
+	default:
+		err = errNoMapping
+	}
+
+	return
+}
+
+func formatInteger(name string, v interface{}) (s string, err error) {
+	i := new(big.Int)
+	switch t := v.(type) {
+	case *big.Int:
+		i.Set(t)
+	default:
+		val := reflect.ValueOf(v)
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i.SetInt64(val.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			i.SetUint64(val.Uint())
+		case reflect.String:
+			if _, ok := i.SetString(strings.TrimPrefix(val.String(), "+"), 10); !ok {
+				err = fmt.Errorf("invalid %s '%s'", name, val.String())
+				return
+			}
+		default:
+			err = errNoMapping
+			return
+		}
+	}
+
+	r := integerRanges[name]
+	if (r[0] != nil && i.Cmp(r[0]) < 0) || (r[1] != nil && i.Cmp(r[1]) > 0) {
+		err = fmt.Errorf("value %s out of range of %s", i, name)
+		return
+	}
+
+	s = i.String()
+	return
+}
+
+func formatDecimal(name string, v interface{}) (s string, err error) {
+	switch t := v.(type) {
+	case *big.Int:
+		s = t.String()
+		return
+	case *big.Float:
+		s = t.Text('f', -1)
+		return
+	case *big.Rat:
+		s, err = formatRat(t)
+		return
+	}
+
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s, err = formatInteger("integer", v)
+	case reflect.Float32, reflect.Float64:
+		f := val.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			err = fmt.Errorf("invalid decimal %v", f)
+			return
+		}
+
+		bits := 64
+		if val.Kind() == reflect.Float32 {
+			bits = 32
+		}
+		s = strconv.FormatFloat(f, 'f', -1, bits)
+	case reflect.String:
+		r, ok := new(big.Rat).SetString(val.String())
+		if !ok || strings.ContainsAny(val.String(), "eE/") {
+			err = fmt.Errorf("invalid decimal '%s'", val.String())
+			return
+		}
+		s, err = formatRat(r)
+	default:
+		s, err = marshalText(v)
+	}
+
+	return
+}
+
+// formatRat returns the decimal representation of r, which must not have
+// infinitely many fractional digits.
+func formatRat(r *big.Rat) (s string, err error) {
+	if r.IsInt() {
+		s = r.Num().String()
+		return
+	}
+
+	// the number of fractional digits of a finite decimal is the larger
+	// exponent of 2 and 5 in its denominator
+	d := new(big.Int).Set(r.Denom())
+	var digits int
+	for _, p := range []int64{2, 5} {
+		var n int
+		for q := big.NewInt(p); new(big.Int).Mod(d, q).Sign() == 0; n++ {
+			d.Div(d, q)
+		}
+		if n > digits {
+			digits = n
+		}
+	}
+
+	if d.Cmp(big.NewInt(1)) != 0 {
+		err = fmt.Errorf("%s has no finite decimal representation", r)
+		return
+	}
+
+	s = r.FloatString(digits)
+	return
+}
+
+func formatFloat(name string, v interface{}) (s string, err error) {
+	bits := 64
+	if name == "float" {
+		bits = 32
+	}
+
+	var f float64
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f = val.Float()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f = float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f = float64(val.Uint())
+	case reflect.String:
+		f, err = strconv.ParseFloat(val.String(), bits)
+		if err != nil {
+			err = fmt.Errorf("invalid %s '%s'", name, val.String())
+			return
+		}
+	default:
+		err = errNoMapping
+		return
+	}
+
+	switch {
+	case math.IsInf(f, 1):
+		s = "INF"
+	case math.IsInf(f, -1):
+		s = "-INF"
+	case math.IsNaN(f):
+		s = "NaN"
+	default:
+		// the canonical form has one digit before the point, at least one
+		// after it and an exponent without sign or leading zeros, e.g.
+		// "1.0E-3"
+		parts := strings.SplitN(strconv.FormatFloat(f, 'E', -1, bits), "E", 2)
+		if !strings.Contains(parts[0], ".") {
+			parts[0] += ".0"
+		}
+
+		var exp int
+		exp, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return
+		}
+
+		s = parts[0] + "E" + strconv.Itoa(exp)
+	}
+
+	return
+}
+
+// timeLayouts are the layouts of the date and time types for time.Time
+// values.
+var timeLayouts = map[string]string{
+	"dateTime":   time.RFC3339Nano,
+	"date":       "2006-01-02",
+	"time":       "15:04:05.999999999Z07:00",
+	"gYearMonth": "2006-01",
+	"gYear":      "2006",
+	"gMonthDay":  "--01-02",
+	"gDay":       "---02",
+	"gMonth":     "--01",
+}
+
+func formatTime(name string, v interface{}) (s string, err error) {
+	switch t := v.(type) {
+	case time.Time:
+		s = t.Format(timeLayouts[name])
+	case string:
+		s = t
+	default:
+		s, err = marshalText(v)
+	}
+
+	return
+}
+
+func formatDuration(name string, v interface{}) (s string, err error) {
+	var d time.Duration
+	switch t := v.(type) {
+	case time.Duration:
+		d = t
+	case string:
+		s = t
+		return
+	default:
+		s, err = marshalText(v)
+		return
+	}
+
+	if d == 0 {
+		s = "PT0S"
+		return
+	}
+
+	var b strings.Builder
+	if d < 0 {
+		b.WriteString("-")
+		d = -d
+	}
+	b.WriteString("PT")
+
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+		d -= h * time.Hour
+	}
+
+	if m := d / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+		d -= m * time.Minute
+	}
+
+	if d > 0 {
+		sec, frac := d/time.Second, d%time.Second
+		if frac == 0 {
+			fmt.Fprintf(&b, "%dS", sec)
+		} else {
+			fmt.Fprintf(&b, "%d.%sS", sec, strings.TrimRight(fmt.Sprintf("%09d", frac), "0"))
+		}
+	}
+
+	s = b.String()
+	return
+}
+
+func formatBinary(name string, v interface{}) (s string, err error) {
+	switch t := v.(type) {
+	case []byte:
+		if name == "hexBinary" {
+			s = strings.ToUpper(hex.EncodeToString(t))
+		} else {
+			s = base64.StdEncoding.EncodeToString(t)
+		}
+	case string:
+		// strings are taken as already encoded
+		if name == "hexBinary" {
+			_, err = hex.DecodeString(t)
+		} else {
+			_, err = base64.StdEncoding.DecodeString(t)
+		}
+		if err != nil {
+			err = fmt.Errorf("invalid %s '%s'", name, t)
+			return
+		}
+		s = t
+	default:
+		s, err = marshalText(v)
+	}
+
+	return
+}
+
+// parseValue returns the Go value of the lexical value s of the builtin type
+// name: a bool, an int64 or a *big.Int if it does not fit, a *big.Rat for
+// decimals, a float64, a time.Time for date and time types, a []byte for
+// binary types and a string otherwise.
+func parseValue(name, s string) (v interface{}, err error) {
+	t := strings.TrimSpace(s)
+	_, integer := integerRanges[name]
+	_, layout := timeLayouts[name]
+	switch {
+	case name == "boolean":
+		switch t {
+		case "true", "1":
+			v = true
+		case "false", "0":
+			v = false
+		default:
+			err = fmt.Errorf("invalid boolean '%s'", t)
+		}
+	case integer:
+		t = strings.TrimPrefix(t, "+")
+		if i, e := strconv.ParseInt(t, 10, 64); e == nil {
+			v = i
+			return
+		}
+
+		i, ok := new(big.Int).SetString(t, 10)
+		if !ok {
+			err = fmt.Errorf("invalid %s '%s'", name, t)
+			return
+		}
+		v = i
+	case name == "decimal":
+		r, ok := new(big.Rat).SetString(t)
+		if !ok || strings.ContainsAny(t, "eE/") {
+			err = fmt.Errorf("invalid decimal '%s'", t)
+			return
+		}
+		v = r
+	case name == "float", name == "double":
+		var f float64
+		f, err = strconv.ParseFloat(t, 64)
+		if err != nil {
+			err = fmt.Errorf("invalid %s '%s'", name, t)
+			return
+		}
+		v = f
+	case layout:
+		tm, ok := parseTime(name, t)
+		if !ok {
+			err = fmt.Errorf("invalid %s '%s'", name, t)
+			return
+		}
+		v = tm
+	case name == "base64Binary", name == "hexBinary":
+		var b []byte
+		if name == "hexBinary" {
+			b, err = hex.DecodeString(t)
+		} else {
+			b, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+		}
+		if err != nil {
+			err = fmt.Errorf("invalid %s '%s'", name, t)
+			return
+		}
+		v = b
+	default:
+		v, err = formatString(name, s)
+	}
+
+	return
+}
+
+func formatString(name string, v interface{}) (s string, err error) {
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.String {
+		s = val.String()
+	} else if s, err = marshalText(v); err != nil {
+		return
+	}
+
+	switch name {
+	case "string", "anySimpleType":
+	case "normalizedString":
+		s = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+	default:
+		// all other string types collapse white space
+		s = strings.Join(strings.Fields(s), " ")
+	}
+
+	return
+}
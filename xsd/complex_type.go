@@ -7,11 +7,17 @@ import (
 )
 
 type ComplexType struct {
-	XMLName  xml.Name        `xml:"http://www.w3.org/2001/XMLSchema complexType"`
-	Name     string          `xml:"name,attr"`
-	Abstract bool            `xml:"abstract,attr"`
-	Sequence []Element       `xml:"sequence>element"`
-	Content  *ComplexContent `xml:"http://www.w3.org/2001/XMLSchema complexContent"`
+	XMLName  xml.Name `xml:"http://www.w3.org/2001/XMLSchema complexType"`
+	Name     string   `xml:"name,attr"`
+	Abstract bool     `xml:"abstract,attr"`
+	ContentModel
+	Content         *ComplexContent  `xml:"http://www.w3.org/2001/XMLSchema complexContent"`
+	SimpleContent   *SimpleContent   `xml:"http://www.w3.org/2001/XMLSchema simpleContent"`
+	Attributes      []Attribute      `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	AttributeGroups []AttributeGroup `xml:"http://www.w3.org/2001/XMLSchema attributeGroup"`
+	// doc is the included document declaring a global type merged into
+	// another schema, see Schema.Merge.
+	doc *Schema
 }
 
 type ComplexContent struct {
@@ -19,46 +25,328 @@ type ComplexContent struct {
 	Extension Extension `xml:"http://www.w3.org/2001/XMLSchema extension"`
 }
 
+type SimpleContent struct {
+	XMLName   xml.Name  `xml:"http://www.w3.org/2001/XMLSchema simpleContent"`
+	Extension Extension `xml:"http://www.w3.org/2001/XMLSchema extension"`
+}
+
 type Extension struct {
-	XMLName  xml.Name  `xml:"http://www.w3.org/2001/XMLSchema extension"`
-	Base     string    `xml:"base,attr"`
-	Sequence []Element `xml:"sequence>element"`
+	XMLName xml.Name `xml:"http://www.w3.org/2001/XMLSchema extension"`
+	Base    string   `xml:"base,attr"`
+	ContentModel
+	Attributes      []Attribute      `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	AttributeGroups []AttributeGroup `xml:"http://www.w3.org/2001/XMLSchema attributeGroup"`
 }
 
-func (self *ComplexType) Encode(enc *xml.Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
-	for _, e := range self.Sequence {
-		err = e.Encode(enc, sr, ga, params, path...)
+func (self *ComplexType) Encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
+	err = self.ContentModel.Encode(enc, sr, ga, params, path...)
+	if err != nil {
+		return
+	}
+
+	if self.Content != nil {
+		err = self.Content.Extension.encodeBase(enc, sr, ga, params, path...)
+		if err != nil {
+			return
+		}
+
+		err = self.Content.Extension.ContentModel.Encode(enc, sr, ga, params, path...)
 		if err != nil {
 			return
 		}
 	}
 
-	if self.Content != nil {
-		parts := strings.Split(self.Content.Extension.Base, ":")
-		switch len(parts) {
-		case 2:
-			var schema Schemaer
-			schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
+	if self.SimpleContent != nil {
+		err = self.SimpleContent.Extension.encodeBase(enc, sr, ga, params, path...)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// EncodeAttributes adds the attributes of the complex type, including the
+// ones of its base type, to start.
+func (self *ComplexType) EncodeAttributes(enc *Encoder, start *xml.StartElement, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
+	err = encodeAttributes(self.Attributes, self.AttributeGroups, enc, start, sr, ga, params, path...)
+	if err != nil {
+		return
+	}
+
+	for _, ext := range []*Extension{self.extension(), self.simpleExtension()} {
+		if ext == nil {
+			continue
+		}
+
+		var schema Schemaer
+		var name string
+		schema, name, err = ext.base(sr, ga, path...)
+		if err != nil {
+			return
+		}
+
+		err = schema.EncodeAttributes(name, enc, start, sr, params, path...)
+		if err != nil {
+			return
+		}
+
+		err = encodeAttributes(ext.Attributes, ext.AttributeGroups, enc, start, sr, ga, params, path...)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Decode decodes the content of the element opened by start into a map keyed
+// by the names of its child elements and attributes like "@id". Elements
+// which may occur more than once give slices, and the text of simple content
+// is keyed by "". Elements the schema does not declare are kept as well.
+func (self *ComplexType) Decode(dec *Decoder, start xml.StartElement, sr SchemaRepository, ga GetAliaser) (v interface{}, err error) {
+	c := content{
+		elements:   map[string]elementDecl{},
+		attributes: map[string]attributeDecl{},
+	}
+	err = c.declare(self, sr, ga, 0)
+	if err != nil {
+		return
+	}
+
+	m := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		if !isAttribute(attr) {
+			continue
+		}
+
+		var av interface{} = attr.Value
+		if decl, ok := c.attributes[attr.Name.Local]; ok {
+			av, err = decl.attr.parse(sr, decl.ga, attr.Value)
 			if err != nil {
 				return
 			}
+		}
+		m["@"+attr.Name.Local] = av
+	}
+
+	var text strings.Builder
+	// undeclared elements occurring more than once, see decodeAny
+	repeated := map[string]bool{}
+	for {
+		var t xml.Token
+		t, err = dec.Token()
+		if err != nil {
+			return
+		}
 
-			err = schema.EncodeType(parts[1], enc, sr, params, path...)
+		switch tok := t.(type) {
+		case xml.CharData:
+			text.Write(tok)
+		case xml.StartElement:
+			name := tok.Name.Local
+			decl, ok := c.elements[name]
+
+			var child interface{}
+			if ok {
+				child, err = decl.elem.Decode(dec, tok, sr, decl.ga)
+			} else {
+				child, err = dec.decodeAny(tok)
+			}
 			if err != nil {
+				err = fmt.Errorf("element '%s': %s", name, err)
 				return
 			}
-		default:
-			err = fmt.Errorf("malformed base '%s' in path %q", self.Content.Extension.Base, path)
+
+			prev, seen := m[name]
+			switch {
+			case ok && decl.repeated:
+				items, _ := prev.([]interface{})
+				m[name] = append(items, child)
+			case ok:
+				m[name] = child
+			case repeated[name]:
+				m[name] = append(prev.([]interface{}), child)
+			case seen:
+				repeated[name] = true
+				m[name] = []interface{}{prev, child}
+			default:
+				m[name] = child
+			}
+		case xml.EndElement:
+			if c.simple != nil {
+				m[""], err = c.simple.ParseType(c.simpleName, sr, text.String())
+			}
+
+			v = m
 			return
 		}
+	}
+}
+
+// content holds the declarations of the child elements and attributes of a
+// complex type including its base types, and the base type of its simple
+// content.
+type content struct {
+	elements   map[string]elementDecl
+	attributes map[string]attributeDecl
+	simple     Schemaer
+	simpleName string
+}
+
+type elementDecl struct {
+	elem     *Element
+	ga       GetAliaser
+	repeated bool
+}
+
+type attributeDecl struct {
+	attr *Attribute
+	ga   GetAliaser
+}
 
-		for _, e := range self.Content.Extension.Sequence {
-			err = e.Encode(enc, sr, ga, params, path...)
+func (self *content) declare(ct *ComplexType, sr SchemaRepository, ga GetAliaser, depth int) (err error) {
+	if p := ct.particle(); p != nil {
+		err = p.declare(sr, ga, false, self.elements)
+		if err != nil {
+			return
+		}
+	}
+
+	err = declareAttributes(ct.Attributes, ct.AttributeGroups, sr, ga, self.attributes)
+	if err != nil {
+		return
+	}
+
+	// a chain this long is most likely a cycle
+	if depth > 64 {
+		err = fmt.Errorf("base types of '%s' nested too deeply", ct.Name)
+		return
+	}
+
+	for _, ext := range []*Extension{ct.extension(), ct.simpleExtension()} {
+		if ext == nil {
+			continue
+		}
+
+		var base Schemaer
+		var name string
+		base, name, err = ext.base(sr, ga)
+		if err != nil {
+			return
+		}
+
+		if s, ok := base.(*Schema); ok && s.complexType(name) != nil {
+			bt := s.complexType(name)
+			err = self.declare(bt, sr, s.scope(bt.doc), depth+1)
+		} else if ext == ct.simpleExtension() {
+			self.simple, self.simpleName = base, name
+		}
+		if err != nil {
+			return
+		}
+
+		if p := ext.particle(); p != nil {
+			err = p.declare(sr, ga, false, self.elements)
 			if err != nil {
 				return
 			}
 		}
+
+		err = declareAttributes(ext.Attributes, ext.AttributeGroups, sr, ga, self.attributes)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// names returns the names of the child elements and attributes like "@id"
+// of the complex type, including those of its base types up to depth.
+func (self *ComplexType) names(sr SchemaRepository, ga GetAliaser, depth int) (names []string) {
+	if p := self.particle(); p != nil {
+		n, _ := p.names(sr, ga)
+		names = append(names, n...)
+	}
+	names = append(names, attributeNames(self.Attributes)...)
+
+	// a chain this long is most likely a cycle
+	if depth > 64 {
+		return
+	}
+
+	for _, ext := range []*Extension{self.extension(), self.simpleExtension()} {
+		if ext == nil {
+			continue
+		}
+
+		if p := ext.particle(); p != nil {
+			n, _ := p.names(sr, ga)
+			names = append(names, n...)
+		}
+		names = append(names, attributeNames(ext.Attributes)...)
+
+		schema, name, err := lookupSchema(ext.Base, sr, ga)
+		if err != nil {
+			continue
+		}
+
+		if ct := schema.complexType(name); ct != nil {
+			names = append(names, ct.names(sr, schema.scope(ct.doc), depth+1)...)
+		}
+	}
+
+	return
+}
+
+func attributeNames(attrs []Attribute) (names []string) {
+	for _, a := range attrs {
+		name := a.Name
+		if a.Ref != "" {
+			name = a.Ref[strings.Index(a.Ref, ":")+1:]
+		}
+		names = append(names, "@"+name)
+	}
+
+	return
+}
+
+func (self *ComplexType) extension() *Extension {
+	if self.Content == nil {
+		return nil
+	}
+	return &self.Content.Extension
+}
+
+func (self *ComplexType) simpleExtension() *Extension {
+	if self.SimpleContent == nil {
+		return nil
+	}
+	return &self.SimpleContent.Extension
+}
+
+func (self *Extension) base(sr SchemaRepository, ga GetAliaser, path ...string) (schema Schemaer, name string, err error) {
+	parts := strings.Split(self.Base, ":")
+	switch len(parts) {
+	case 2:
+		schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
+		name = parts[1]
+	default:
+		err = fmt.Errorf("malformed base '%s' in path %q", self.Base, path)
+	}
+
+	return
+}
+
+func (self *Extension) encodeBase(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
+	var schema Schemaer
+	var name string
+	schema, name, err = self.base(sr, ga, path...)
+	if err != nil {
+		return
 	}
 
+	err = schema.EncodeType(name, enc, sr, params, path...)
 	return
 }
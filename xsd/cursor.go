@@ -0,0 +1,186 @@
+package xsd
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The encoder walks the params read-only and keeps track of the values it
+// has consumed, so that the same params can be encoded many times and
+// concurrently. A slice holds a value for every occurrence of an element,
+// which are consumed one after the other.
+
+// LeftoverError lists the paths of params which were not consumed.
+type LeftoverError []string
+
+func (self LeftoverError) Error() string {
+	return fmt.Sprintf("leftover parameters %q", []string(self))
+}
+
+// Leftover returns a LeftoverError of the params not consumed so far or
+// nil.
+func (self *Encoder) Leftover(params map[string]interface{}) error {
+	var keys []string
+	for k := range params {
+		if self.left(params, k) > 0 {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	sort.Strings(keys)
+	return LeftoverError(keys)
+}
+
+// ViolateUnmatched records the params not consumed so far, whose first
+// element matches none of the root elements names like the header and body
+// elements of an operation, as violations suggesting a similar root like
+// "get/id" for "gett/id". If the encoder is strict, they are consumed and
+// left out of Leftover.
+func (self *Encoder) ViolateUnmatched(params map[string]interface{}, names ...string) {
+	if !self.Strict {
+		return
+	}
+
+	var keys []string
+	for k := range params {
+		if self.left(params, k) <= 0 {
+			continue
+		}
+
+		var known bool
+		for _, n := range names {
+			known = known || root(k) == n
+		}
+		if !known {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		self.consume(k, self.left(params, k))
+
+		r := root(k)
+		if s := suggest(r, names); s != "" {
+			self.Violate(k, "matches nothing in the schema, did you mean '%s'?", s+strings.TrimPrefix(k, r))
+		} else {
+			self.Violate(k, "matches nothing in the schema")
+		}
+	}
+}
+
+// root returns the name of the first element of the param path key.
+func root(key string) string {
+	if i := strings.IndexAny(key, "/["); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// isSlice reports whether val holds values of several occurrences, which
+// is true for slices other than []byte.
+func isSlice(val reflect.Value) bool {
+	return val.Kind() == reflect.Slice && val.Type().Elem().Kind() != reflect.Uint8
+}
+
+// left returns the number of values of key not consumed yet.
+func (self *Encoder) left(params map[string]interface{}, key string) int {
+	v, ok := params[key]
+	if !ok {
+		return 0
+	}
+
+	n := 1
+	if val := reflect.ValueOf(v); isSlice(val) {
+		n = val.Len()
+	}
+	return n - self.consumed[key]
+}
+
+func (self *Encoder) consume(key string, n int) {
+	if self.consumed == nil {
+		self.consumed = map[string]int{}
+	}
+	self.consumed[key] += n
+}
+
+// peek returns the next value of key without consuming it.
+func (self *Encoder) peek(params map[string]interface{}, key string) (v interface{}, ok bool) {
+	if self.left(params, key) <= 0 {
+		return
+	}
+
+	v, ok = params[key], true
+	if val := reflect.ValueOf(v); isSlice(val) {
+		v = val.Index(self.consumed[key]).Interface()
+	}
+	return
+}
+
+// value consumes the next value of key. If the value is a slice, this is
+// the next item, so repeated elements get one item each.
+func (self *Encoder) value(params map[string]interface{}, key string) (v interface{}, ok bool) {
+	v, ok = self.peek(params, key)
+	if ok {
+		self.consume(key, 1)
+	}
+	return
+}
+
+// take consumes all values of key left at once, e.g. a slice for a list.
+func (self *Encoder) take(params map[string]interface{}, key string) (v interface{}, ok bool) {
+	n := self.left(params, key)
+	if n <= 0 {
+		return
+	}
+
+	v, ok = params[key], true
+	if val := reflect.ValueOf(v); isSlice(val) {
+		v = val.Slice(self.consumed[key], val.Len()).Interface()
+	}
+
+	self.consume(key, n)
+	return
+}
+
+// counts returns the number of values left of every param below prefix.
+func (self *Encoder) counts(params map[string]interface{}, prefix string) map[string]int {
+	c := map[string]int{}
+	for k := range params {
+		if !isPathPrefix(k, prefix) {
+			continue
+		}
+
+		if n := self.left(params, k); n > 0 {
+			c[k] = n
+		}
+	}
+
+	return c
+}
+
+// remaining counts the values left below prefix.
+func (self *Encoder) remaining(params map[string]interface{}, prefix string) (n int) {
+	for _, c := range self.counts(params, prefix) {
+		n += c
+	}
+
+	return
+}
+
+// skip consumes all values left below prefix and returns their paths.
+func (self *Encoder) skip(params map[string]interface{}, prefix string) (keys []string) {
+	for k, n := range self.counts(params, prefix) {
+		keys = append(keys, k)
+		self.consume(k, n)
+	}
+
+	sort.Strings(keys)
+	return
+}
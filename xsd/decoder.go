@@ -0,0 +1,207 @@
+package xsd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Decoder reads XML like an xml.Decoder, but leaves out elements with
+// xsi:nil set when decoding into values, so that their pointer fields stay
+// nil. It also keeps track of the namespace prefixes in scope, which resolve
+// qualified names like xsi:type values while decoding elements against a
+// schema, where elements with xsi:nil set give nil.
+type Decoder struct {
+	*xml.Decoder
+	scopes []map[string]string
+	nils   *nilReader
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	nils := &nilReader{d: xml.NewDecoder(r)}
+	return &Decoder{
+		Decoder: xml.NewTokenDecoder(nils),
+		nils:    nils,
+	}
+}
+
+// Decode decodes the next element into v like the one of xml.Decoder,
+// leaving out elements with xsi:nil set.
+func (self *Decoder) Decode(v interface{}) error {
+	self.nils.skip = true
+	defer func() { self.nils.skip = false }()
+
+	return self.Decoder.Decode(v)
+}
+
+// Token returns the next token like the one of xml.Decoder and records the
+// namespace declarations of start elements until their end.
+func (self *Decoder) Token() (t xml.Token, err error) {
+	t, err = self.Decoder.Token()
+	if err != nil {
+		return
+	}
+
+	switch tok := t.(type) {
+	case xml.StartElement:
+		scope := map[string]string{}
+		for _, attr := range tok.Attr {
+			switch {
+			case attr.Name.Space == "xmlns":
+				scope[attr.Name.Local] = attr.Value
+			case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+				scope[""] = attr.Value
+			}
+		}
+		self.scopes = append(self.scopes, scope)
+	case xml.EndElement:
+		if len(self.scopes) > 0 {
+			self.scopes = self.scopes[:len(self.scopes)-1]
+		}
+	}
+
+	return
+}
+
+// Skip reads tokens until the end of the element most recently started,
+// like the one of xml.Decoder.
+func (self *Decoder) Skip() error {
+	for depth := 0; ; {
+		t, err := self.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		}
+	}
+}
+
+// resolve returns the namespace and the local name of the qualified name
+// qname like 'tns:Type' declared by the elements read so far.
+func (self *Decoder) resolve(qname string) (space, local string) {
+	var prefix string
+	local = qname
+	if i := strings.Index(qname, ":"); i >= 0 {
+		prefix, local = qname[:i], qname[i+1:]
+	}
+
+	for i := len(self.scopes) - 1; i >= 0; i-- {
+		if s, ok := self.scopes[i][prefix]; ok {
+			space = s
+			return
+		}
+	}
+
+	return
+}
+
+// text returns the character data of the element start, which must not have
+// child elements.
+func (self *Decoder) text(start xml.StartElement) (s string, err error) {
+	var b strings.Builder
+	for {
+		var t xml.Token
+		t, err = self.Token()
+		if err != nil {
+			return
+		}
+
+		switch tok := t.(type) {
+		case xml.CharData:
+			b.Write(tok)
+		case xml.StartElement:
+			err = fmt.Errorf("unexpected element '%s' in simple element '%s'", tok.Name.Local, start.Name.Local)
+			return
+		case xml.EndElement:
+			s = b.String()
+			return
+		}
+	}
+}
+
+// decodeAny decodes the element start, which the schema does not describe.
+// Elements with attributes or child elements give maps like the ones of
+// complex types, where children occurring more than once give slices.
+// Others give their text, and those with xsi:nil set give nil.
+func (self *Decoder) decodeAny(start xml.StartElement) (v interface{}, err error) {
+	if isNil(start) {
+		err = self.Skip()
+		return
+	}
+
+	m := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		if isAttribute(attr) {
+			m["@"+attr.Name.Local] = attr.Value
+		}
+	}
+
+	var text strings.Builder
+	var children bool
+	repeated := map[string]bool{}
+	for {
+		var t xml.Token
+		t, err = self.Token()
+		if err != nil {
+			return
+		}
+
+		switch tok := t.(type) {
+		case xml.CharData:
+			text.Write(tok)
+		case xml.StartElement:
+			children = true
+
+			var child interface{}
+			child, err = self.decodeAny(tok)
+			if err != nil {
+				return
+			}
+
+			name := tok.Name.Local
+			prev, ok := m[name]
+			switch {
+			case repeated[name]:
+				m[name] = append(prev.([]interface{}), child)
+			case ok:
+				repeated[name] = true
+				m[name] = []interface{}{prev, child}
+			default:
+				m[name] = child
+			}
+		case xml.EndElement:
+			switch {
+			case children:
+				v = m
+			case len(m) > 0:
+				m[""] = text.String()
+				v = m
+			default:
+				v = text.String()
+			}
+			return
+		}
+	}
+}
+
+// isAttribute reports whether attr is an attribute of the content rather
+// than a namespace declaration or an attribute like xsi:type.
+func isAttribute(attr xml.Attr) bool {
+	switch {
+	case attr.Name.Space == "xmlns", attr.Name.Space == "" && attr.Name.Local == "xmlns":
+		return false
+	case attr.Name.Space == XMLSchemaInstanceNamespace:
+		return false
+	}
+
+	return true
+}
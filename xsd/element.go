@@ -3,6 +3,7 @@ package xsd
 import (
 	"encoding/xml"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -15,58 +16,331 @@ type Element struct {
 	Form         string       `xml:"form,attr"`
 	Name         string       `xml:"name,attr"`
 	ComplexTypes *ComplexType `xml:"http://www.w3.org/2001/XMLSchema complexType"`
+	SimpleType   *SimpleType  `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+	// doc is the included document declaring a global element merged into
+	// another schema, see Schema.Merge.
+	doc *Schema
 }
 
-func (self *Element) Encode(enc *xml.Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
-	if self.MinOccurs != "" && self.MinOccurs == "0" && !hasPrefix(params, MakePath(append(path, self.Name))) {
+// Occurs returns the minOccurs and maxOccurs of the element, where a max of
+// -1 means unbounded.
+func (self *Element) Occurs() (min, max int, err error) {
+	min, max, err = occurs(self.MinOccurs, self.MaxOccurs)
+	if err != nil {
+		err = fmt.Errorf("%s of element '%s'", err, self.Name)
+	}
+	return
+}
+
+// Encode writes the element once for every set of params below its path.
+// Occurrences violating minOccurs or maxOccurs, as well as params which are
+// not consumed by the element, are recorded as violations of enc.
+func (self *Element) Encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) error {
+	return self.encode(enc, sr, ga, params, enc.limit, path...)
+}
+
+// encode writes the occurrences of the element. If limit is set, it stops
+// at maxOccurs and leaves further params to the next occurrence of the
+// repeated group containing the element.
+func (self *Element) encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, limit bool, path ...string) (err error) {
+	elemPath := append(path[:len(path):len(path)], self.Name)
+	key := MakePath(elemPath)
+
+	var min, max int
+	min, max, err = self.Occurs()
+	if err != nil {
 		return
 	}
 
-	for hasPrefix(params, MakePath(append(path, self.Name))) {
-		start := xml.StartElement{
-			Name: xml.Name{
-				Space: ga.Namespace(),
-				Local: self.Name,
-			},
+	initial := enc.counts(params, key)
+	if idx := indices(initial, key); len(idx) > 0 {
+		err = self.encodeIndexed(enc, sr, ga, params, limit, idx, path...)
+		return
+	}
+
+	var n int
+	for ; enc.remaining(params, key) > 0; n++ {
+		if max >= 0 && n >= max {
+			if limit {
+				break
+			}
+
+			// params never touched by an occurrence match nothing in the
+			// element rather than adding another occurrence
+			var unmatched []string
+			left := enc.counts(params, key)
+			for k, c := range left {
+				if c == initial[k] {
+					unmatched = append(unmatched, k)
+				}
+			}
+			sort.Strings(unmatched)
+
+			if len(unmatched) < len(left) {
+				enc.Violate(key, "occurs more than maxOccurs %d times", max)
+			}
+			self.violateUnmatched(enc, sr, ga, key, unmatched)
+			enc.skip(params, key)
+			break
 		}
 
-		err = enc.EncodeToken(start)
+		before := enc.remaining(params, key)
+		outer := enc.limit
+		enc.limit = limit || max != 1
+		err = self.encodeOnce(enc, sr, ga, params, elemPath...)
+		enc.limit = outer
 		if err != nil {
 			return
 		}
 
-		if self.Type != "" {
-			parts := strings.Split(self.Type, ":")
-			switch len(parts) {
-			case 2:
-				var schema Schemaer
-				schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
-				if err != nil {
-					return
-				}
+		if enc.remaining(params, key) >= before {
+			self.violateUnmatched(enc, sr, ga, key, enc.skip(params, key))
+			n++
+			break
+		}
+	}
 
-				err = schema.EncodeType(parts[1], enc, sr, params, append(path, self.Name)...)
-				if err != nil {
-					return
-				}
-			default:
-				err = fmt.Errorf("malformed type '%s' in path %q", self.Type, path)
-				return
+	if n < min {
+		enc.Violate(key, "occurs %d times, want at least minOccurs %d", n, min)
+	}
+
+	return
+}
+
+// encodeIndexed writes an occurrence of the element for each of the indices
+// of params like "mutate/operations[0]/operator", which keep the params of
+// every occurrence apart.
+func (self *Element) encodeIndexed(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, limit bool, indices []int, path ...string) (err error) {
+	key := MakePath(append(path[:len(path):len(path)], self.Name))
+
+	var min, max int
+	min, max, err = self.Occurs()
+	if err != nil {
+		return
+	}
+
+	for n, i := range indices {
+		if max >= 0 && n >= max {
+			for _, j := range indices[n:] {
+				enc.skip(params, MakePath(append(path[:len(path):len(path)], fmt.Sprintf("%s[%d]", self.Name, j))))
 			}
-		} else if self.ComplexTypes != nil {
-			for _, e := range self.ComplexTypes.Sequence {
-				err = e.Encode(enc, sr, ga, params, append(path, self.Name)...)
-				if err != nil {
-					return
+
+			// params without index add another occurrence
+			occurs := len(indices)
+			for k := range enc.counts(params, key) {
+				if k == key || strings.HasPrefix(k, key+"/") {
+					enc.skip(params, k)
+					occurs = len(indices) + 1
 				}
 			}
+
+			enc.Violate(key, "occurs %d times, want at most maxOccurs %d", occurs, max)
+			break
 		}
 
-		err = enc.EncodeToken(start.End())
+		elemPath := append(path[:len(path):len(path)], fmt.Sprintf("%s[%d]", self.Name, i))
+		outer := enc.limit
+		enc.limit = limit
+		err = self.encodeOnce(enc, sr, ga, params, elemPath...)
+		enc.limit = outer
 		if err != nil {
 			return
 		}
+
+		indexKey := MakePath(elemPath)
+		self.violateUnmatched(enc, sr, ga, indexKey, enc.skip(params, indexKey))
+	}
+
+	if len(indices) < min {
+		enc.Violate(key, "occurs %d times, want at least minOccurs %d", len(indices), min)
+	}
+
+	// e.g. params without index or with a malformed one
+	self.violateUnmatched(enc, sr, ga, key, enc.skip(params, key))
+
+	return
+}
+
+// violateUnmatched records the params keys below the element at key, which
+// match nothing in its type, suggesting similar names of its children.
+func (self *Element) violateUnmatched(enc *Encoder, sr SchemaRepository, ga GetAliaser, key string, keys []string) {
+	if !enc.Strict || len(keys) == 0 {
+		return
+	}
+
+	names := self.names(sr, ga)
+	for _, k := range keys {
+		// the name of the child, e.g. "serviceSelecter" of
+		// "get/serviceSelecter/fields" below "get"
+		rest := strings.TrimPrefix(k, key)
+		if !strings.HasPrefix(rest, "/") {
+			enc.Violate(k, "matches nothing in the schema")
+			continue
+		}
+
+		name := rest[1:]
+		if i := strings.IndexAny(name, "/["); i >= 0 {
+			name = name[:i]
+		}
+
+		if s := suggest(name, names); s != "" {
+			enc.Violate(k, "matches nothing in the schema, did you mean '%s'?", key+"/"+s+strings.TrimPrefix(rest[1:], name))
+		} else {
+			enc.Violate(k, "matches nothing in the schema")
+		}
+	}
+}
+
+// names returns the names of the child elements and attributes like "@id"
+// of the element.
+func (self *Element) names(sr SchemaRepository, ga GetAliaser) []string {
+	if self.ComplexTypes != nil {
+		return self.ComplexTypes.names(sr, ga, 0)
+	}
+
+	schema, name, err := lookupSchema(self.Type, sr, ga)
+	if err != nil {
+		return nil
+	}
+
+	if ct := schema.complexType(name); ct != nil {
+		return ct.names(sr, schema.scope(ct.doc), 0)
+	}
+	return nil
+}
+
+// Decode decodes the element opened by start into a value of its type, see
+// ComplexType.Decode and SimpleType.Parse. A type given by xsi:type replaces
+// the declared type and is kept as "@xsi:type" like '{namespace}local',
+// which encodes the same type again. Elements with xsi:nil set give nil.
+func (self *Element) Decode(dec *Decoder, start xml.StartElement, sr SchemaRepository, ga GetAliaser) (v interface{}, err error) {
+	if isNil(start) {
+		err = dec.Skip()
+		return
+	}
+
+	for _, attr := range start.Attr {
+		if attr.Name.Space != XMLSchemaInstanceNamespace || attr.Name.Local != "type" {
+			continue
+		}
+
+		space, local := dec.resolve(attr.Value)
+		var schema Schemaer
+		schema, err = sr.GetSchema(space)
+		if err != nil {
+			return
+		}
+
+		v, err = schema.DecodeType(local, dec, start, sr)
+		if m, ok := v.(map[string]interface{}); ok {
+			m["@xsi:type"] = "{" + space + "}" + local
+		}
+		return
+	}
+
+	switch {
+	case self.Type != "":
+		parts := strings.Split(self.Type, ":")
+		if len(parts) != 2 {
+			err = fmt.Errorf("malformed type '%s' of element '%s'", self.Type, self.Name)
+			return
+		}
+
+		var schema Schemaer
+		schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
+		if err != nil {
+			return
+		}
+
+		v, err = schema.DecodeType(parts[1], dec, start, sr)
+	case self.ComplexTypes != nil:
+		v, err = self.ComplexTypes.Decode(dec, start, sr, ga)
+	case self.SimpleType != nil:
+		var s string
+		s, err = dec.text(start)
+		if err != nil {
+			return
+		}
+
+		v, err = self.SimpleType.Parse(sr, ga, s)
+	default:
+		v, err = dec.decodeAny(start)
+	}
+
+	return
+}
+
+func (self *Element) encodeOnce(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
+	start := xml.StartElement{
+		Name: xml.Name{
+			Local: self.Name,
+		},
+	}
+
+	if ga.ElementQualified(self.Form) {
+		start.Name.Space = ga.Namespace()
+	} else {
+		// unqualified elements must not inherit the default namespace of
+		// their parent
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}})
+	}
+
+	var schema Schemaer
+	var typeName string
+	if self.Type != "" {
+		parts := strings.Split(self.Type, ":")
+		switch len(parts) {
+		case 2:
+			schema, typeName, err = instanceType(enc, sr, ga.GetAlias(parts[0]), parts[1], &start, params, path...)
+			if err != nil {
+				return
+			}
+
+			err = schema.EncodeAttributes(typeName, enc, &start, sr, params, path...)
+		default:
+			err = fmt.Errorf("malformed type '%s' in path %q", self.Type, path)
+		}
+	} else if self.ComplexTypes != nil {
+		err = self.ComplexTypes.EncodeAttributes(enc, &start, sr, ga, params, path...)
+	}
+	if err != nil {
+		return
+	}
+
+	key := MakePath(path)
+	if v, ok := enc.peek(params, key); ok && v == Nil {
+		enc.value(params, key)
+		if self.Nillable != "true" && self.Nillable != "1" {
+			enc.Violate(key, "element '%s' is not nillable", self.Name)
+			return
+		}
+
+		encodeNil(enc, &start)
+		err = enc.EncodeToken(start)
+		if err != nil {
+			return
+		}
+
+		err = enc.EncodeToken(start.End())
+		return
+	}
+
+	err = enc.EncodeToken(start)
+	if err != nil {
+		return
+	}
+
+	if schema != nil {
+		err = schema.EncodeType(typeName, enc, sr, params, path...)
+	} else if self.ComplexTypes != nil {
+		err = self.ComplexTypes.Encode(enc, sr, ga, params, path...)
+	} else if self.SimpleType != nil {
+		err = self.SimpleType.Encode(enc, sr, ga, params, path...)
+	}
+	if err != nil {
+		return
 	}
 
+	err = enc.EncodeToken(start.End())
 	return
 }
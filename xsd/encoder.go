@@ -0,0 +1,175 @@
+package xsd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// Encoder writes XML for the elements and types of schemas. Besides the
+// tokens written by its embedded xml.Encoder, it collects the violations
+// found while encoding, so that all of them can be reported at once.
+type Encoder struct {
+	*xml.Encoder
+	violations ValidationError
+	prefixes   map[string]string
+	used       map[string]bool
+	consumed   map[string]int
+
+	// explicit is set if namespaces are written as prefixes, which are
+	// declared by an enclosing element, instead of by every element.
+	explicit bool
+
+	// limit is set while encoding the content of a repeated element, whose
+	// children then stop at their maxOccurs and leave further params to the
+	// next occurrence.
+	limit bool
+
+	// Strict makes the encoder report params which match nothing in the
+	// schema as violations, suggesting similar names. Otherwise they are
+	// only left over, see Leftover.
+	Strict bool
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		Encoder: xml.NewEncoder(w),
+	}
+}
+
+// SetPrefixes makes the encoder write qualified names with explicit
+// prefixes, preferring the given ones keyed by namespace. Namespace
+// declarations of tokens are dropped, as Namespaces returns the declarations
+// for the enclosing element instead.
+func (self *Encoder) SetPrefixes(prefixes map[string]string) {
+	self.explicit = true
+	self.prefixes = map[string]string{}
+	for space, prefix := range prefixes {
+		self.prefixes[space] = prefix
+	}
+}
+
+// Prefix returns the prefix used for the namespace space in qualified
+// names like xsi:type values.
+func (self *Encoder) Prefix(space string) string {
+	if space == xmlNamespace {
+		return "xml"
+	}
+
+	if self.prefixes == nil {
+		self.prefixes = map[string]string{}
+	}
+	if self.used == nil {
+		self.used = map[string]bool{}
+	}
+	self.used[space] = true
+
+	p, ok := self.prefixes[space]
+	if !ok {
+		taken := map[string]bool{}
+		for _, q := range self.prefixes {
+			taken[q] = true
+		}
+
+		for n := 1; ; n++ {
+			p = fmt.Sprintf("ns%d", n)
+			if !taken[p] {
+				break
+			}
+		}
+		self.prefixes[space] = p
+	}
+	return p
+}
+
+// Name returns name with its namespace replaced by a prefix.
+func (self *Encoder) Name(name xml.Name) xml.Name {
+	if name.Space == "" {
+		return name
+	}
+
+	return xml.Name{Local: self.Prefix(name.Space) + ":" + name.Local}
+}
+
+// Namespaces returns the declarations of the namespaces used so far,
+// ordered by prefix.
+func (self *Encoder) Namespaces() (attrs []xml.Attr) {
+	for space := range self.used {
+		attrs = append(attrs, xml.Attr{
+			Name:  xml.Name{Local: "xmlns:" + self.prefixes[space]},
+			Value: space,
+		})
+	}
+
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Name.Local < attrs[j].Name.Local
+	})
+	return
+}
+
+// EncodeToken writes t. If prefixes are set, the names of elements and
+// attributes get their prefixes and namespace declarations are dropped.
+func (self *Encoder) EncodeToken(t xml.Token) error {
+	if !self.explicit {
+		return self.Encoder.EncodeToken(t)
+	}
+
+	switch tok := t.(type) {
+	case xml.StartElement:
+		start := xml.StartElement{Name: self.Name(tok.Name)}
+		for _, attr := range tok.Attr {
+			if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && (attr.Name.Local == "xmlns" || strings.HasPrefix(attr.Name.Local, "xmlns:"))) {
+				continue
+			}
+
+			start.Attr = append(start.Attr, xml.Attr{Name: self.Name(attr.Name), Value: attr.Value})
+		}
+		t = start
+	case xml.EndElement:
+		t = xml.EndElement{Name: self.Name(tok.Name)}
+	}
+
+	return self.Encoder.EncodeToken(t)
+}
+
+// Violate records a violation of the schema for the param path.
+func (self *Encoder) Violate(path string, format string, v ...interface{}) {
+	self.violations = append(self.violations, Violation{
+		Path:    path,
+		Message: fmt.Sprintf(format, v...),
+	})
+}
+
+// Err returns a ValidationError of all violations recorded so far or nil.
+func (self *Encoder) Err() error {
+	if len(self.violations) == 0 {
+		return nil
+	}
+	return self.violations
+}
+
+// Violation is a param path which does not satisfy the schema.
+type Violation struct {
+	Path    string
+	Message string
+}
+
+func (self Violation) String() string {
+	return fmt.Sprintf("'%s': %s", self.Path, self.Message)
+}
+
+// ValidationError lists all violations of a request.
+type ValidationError []Violation
+
+func (self ValidationError) Error() string {
+	s := make([]string, len(self))
+	for i, v := range self {
+		s[i] = v.String()
+	}
+
+	return fmt.Sprintf("invalid parameters: %s", strings.Join(s, "; "))
+}
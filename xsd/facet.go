@@ -0,0 +1,322 @@
+package xsd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Facet is a constraining facet of a restriction like
+// '<maxLength value="5"/>'.
+type Facet struct {
+	Value string `xml:"value,attr"`
+	Fixed bool   `xml:"fixed,attr"`
+}
+
+// FacetError is a value which violates a facet of a simple type.
+type FacetError struct {
+	Facet string
+	Limit string
+	Value string
+}
+
+func (self *FacetError) Error() string {
+	return fmt.Sprintf("value '%s' violates facet %s %s", self.Value, self.Facet, self.Limit)
+}
+
+// validate normalizes the white space of the lexical value s and checks it
+// against the facets of the restriction. primitive is the builtin type the
+// restriction derives from, which tells how to measure and compare values.
+func (self *SimpleTypeRestriction) validate(primitive, s string) (v string, err error) {
+	v = s
+	if self.WhiteSpace != nil {
+		switch self.WhiteSpace.Value {
+		case "replace":
+			v = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(v)
+		case "collapse":
+			v = strings.Join(strings.Fields(v), " ")
+		}
+	}
+
+	if len(self.Enumerations) > 0 {
+		values := make([]string, len(self.Enumerations))
+		var found bool
+		for i, e := range self.Enumerations {
+			values[i] = e.Value
+			found = found || e.Value == v
+		}
+
+		if !found {
+			err = &FacetError{Facet: "enumeration", Limit: fmt.Sprintf("%q", values), Value: v}
+			return
+		}
+	}
+
+	if len(self.Patterns) > 0 {
+		// patterns of the same restriction are alternatives
+		var matched bool
+		for _, p := range self.Patterns {
+			var re *regexp.Regexp
+			re, err = compilePattern(p.Value)
+			if err != nil {
+				err = fmt.Errorf("malformed pattern '%s': %s", p.Value, err)
+				return
+			}
+
+			matched = matched || re.MatchString(v)
+		}
+
+		if !matched {
+			err = &FacetError{Facet: "pattern", Limit: fmt.Sprintf("%q", self.Patterns[0].Value), Value: v}
+			return
+		}
+	}
+
+	for _, f := range []struct {
+		name  string
+		facet *Facet
+		ok    func(n, limit int) bool
+	}{
+		{"length", self.Length, func(n, limit int) bool { return n == limit }},
+		{"minLength", self.MinLength, func(n, limit int) bool { return n >= limit }},
+		{"maxLength", self.MaxLength, func(n, limit int) bool { return n <= limit }},
+	} {
+		if f.facet == nil {
+			continue
+		}
+
+		var limit int
+		limit, err = strconv.Atoi(f.facet.Value)
+		if err != nil {
+			err = fmt.Errorf("malformed %s '%s'", f.name, f.facet.Value)
+			return
+		}
+
+		if !f.ok(length(primitive, v), limit) {
+			err = &FacetError{Facet: f.name, Limit: f.facet.Value, Value: v}
+			return
+		}
+	}
+
+	for _, f := range []struct {
+		name  string
+		facet *Facet
+		ok    func(c int) bool
+	}{
+		{"minInclusive", self.MinInclusive, func(c int) bool { return c >= 0 }},
+		{"maxInclusive", self.MaxInclusive, func(c int) bool { return c <= 0 }},
+		{"minExclusive", self.MinExclusive, func(c int) bool { return c > 0 }},
+		{"maxExclusive", self.MaxExclusive, func(c int) bool { return c < 0 }},
+	} {
+		if f.facet == nil {
+			continue
+		}
+
+		if c, ok := compare(primitive, v, f.facet.Value); ok && !f.ok(c) {
+			err = &FacetError{Facet: f.name, Limit: f.facet.Value, Value: v}
+			return
+		}
+	}
+
+	if self.TotalDigits != nil || self.FractionDigits != nil {
+		total, fraction, ok := digits(v)
+		if !ok {
+			return
+		}
+
+		for _, f := range []struct {
+			name  string
+			facet *Facet
+			n     int
+		}{
+			{"totalDigits", self.TotalDigits, total},
+			{"fractionDigits", self.FractionDigits, fraction},
+		} {
+			if f.facet == nil {
+				continue
+			}
+
+			var limit int
+			limit, err = strconv.Atoi(f.facet.Value)
+			if err != nil {
+				err = fmt.Errorf("malformed %s '%s'", f.name, f.facet.Value)
+				return
+			}
+
+			if f.n > limit {
+				err = &FacetError{Facet: f.name, Limit: f.facet.Value, Value: v}
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// length returns the length of s as defined for the length facets of
+// primitive, i.e. items for lists, octets for binary types and characters
+// otherwise.
+func length(primitive, s string) int {
+	switch primitive {
+	case "list":
+		return len(strings.Fields(s))
+	case "hexBinary":
+		return len(s) / 2
+	case "base64Binary":
+		if b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), "")); err == nil {
+			return len(b)
+		}
+	}
+
+	return utf8.RuneCountInString(s)
+}
+
+// compare orders the lexical values a and b of primitive. It reports false
+// if primitive is not ordered or either value cannot be parsed.
+func compare(primitive, a, b string) (c int, ok bool) {
+	if _, layout := timeLayouts[primitive]; layout {
+		var ta, tb time.Time
+		if ta, ok = parseTime(primitive, a); !ok {
+			return
+		}
+		if tb, ok = parseTime(primitive, b); !ok {
+			return
+		}
+
+		switch {
+		case ta.Before(tb):
+			c = -1
+		case ta.After(tb):
+			c = 1
+		}
+		return
+	}
+
+	switch _, integer := integerRanges[primitive]; {
+	case integer, primitive == "decimal", primitive == "float", primitive == "double":
+	default:
+		return
+	}
+
+	ra, okA := new(big.Rat).SetString(a)
+	rb, okB := new(big.Rat).SetString(b)
+	if !okA || !okB {
+		return
+	}
+
+	c, ok = ra.Cmp(rb), true
+	return
+}
+
+// parseTime parses the lexical value s of a date or time type with or
+// without a time zone.
+func parseTime(primitive, s string) (t time.Time, ok bool) {
+	layout := strings.TrimSuffix(timeLayouts[primitive], "Z07:00")
+	for _, l := range []string{layout, layout + "Z07:00"} {
+		if p, err := time.Parse(l, s); err == nil {
+			t, ok = p, true
+			return
+		}
+	}
+
+	return
+}
+
+// digits counts the significant digits and the fractional digits of the
+// decimal s, reporting false if s is not a decimal.
+func digits(s string) (total, fraction int, ok bool) {
+	s = strings.TrimLeft(s, "+-")
+	i, f := s, ""
+	if n := strings.Index(s, "."); n >= 0 {
+		i, f = s[:n], s[n+1:]
+	}
+
+	if i+f == "" || strings.Trim(i+f, "0123456789") != "" {
+		return
+	}
+
+	i = strings.TrimLeft(i, "0")
+	f = strings.TrimRight(f, "0")
+	total, fraction, ok = len(i)+len(f), len(f), true
+	if total == 0 {
+		total = 1
+	}
+	return
+}
+
+// nameStart and nameChar are the characters of the multi-character escapes
+// \i and \c, which match the first and the other characters of XML names.
+const (
+	nameStart = `\p{L}\p{Nl}_:`
+	nameChar  = `\p{L}\p{Nl}\p{Nd}\p{Mn}\p{Mc}_:.\-`
+)
+
+// compilePattern compiles the XSD regular expression p. XSD patterns always
+// match the whole value and know no anchors, so '^' and '$' are literal
+// characters, and '.' matches neither newline nor carriage return. Character
+// class subtraction like '[a-z-[aeiou]]' is not supported.
+func compilePattern(p string) (re *regexp.Regexp, err error) {
+	var b strings.Builder
+	b.WriteString("^(?:")
+
+	rs := []rune(p)
+	var class bool
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '\\' && i+1 < len(rs):
+			i++
+			set := ""
+			switch rs[i] {
+			case 'i', 'I':
+				set = nameStart
+			case 'c', 'C':
+				set = nameChar
+			default:
+				b.WriteRune(r)
+				b.WriteRune(rs[i])
+				continue
+			}
+
+			negated := rs[i] == 'I' || rs[i] == 'C'
+			switch {
+			case class && negated:
+				err = fmt.Errorf("escape '\\%c' in a character class is not supported", rs[i])
+				return
+			case class:
+				b.WriteString(set)
+			case negated:
+				b.WriteString("[^" + set + "]")
+			default:
+				b.WriteString("[" + set + "]")
+			}
+		case class && r == '-' && i+1 < len(rs) && rs[i+1] == '[':
+			err = fmt.Errorf("character class subtraction is not supported")
+			return
+		case class && r == ']':
+			class = false
+			b.WriteRune(r)
+		case class:
+			b.WriteRune(r)
+		case r == '[':
+			class = true
+			b.WriteRune(r)
+		case r == '^' || r == '$':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == '.':
+			b.WriteString(`[^\n\r]`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	b.WriteString(")$")
+	re, err = regexp.Compile(b.String())
+	return
+}
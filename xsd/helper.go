@@ -2,34 +2,120 @@ package xsd
 
 import (
 	"encoding/xml"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 type Schemaer interface {
-	EncodeElement(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) (err error)
-	EncodeType(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) (err error)
+	EncodeElement(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) (err error)
+	EncodeType(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) (err error)
+	EncodeAttributes(name string, enc *Encoder, start *xml.StartElement, sr SchemaRepository, params map[string]interface{}, path ...string) (err error)
+	FormatType(name string, sr SchemaRepository, v interface{}) (s string, err error)
+	DecodeElement(name string, dec *Decoder, start xml.StartElement, sr SchemaRepository) (v interface{}, err error)
+	DecodeType(name string, dec *Decoder, start xml.StartElement, sr SchemaRepository) (v interface{}, err error)
+	ParseType(name string, sr SchemaRepository, s string) (v interface{}, err error)
 }
 
 type GetAliaser interface {
 	GetAlias(string) string
 	Namespace() string
+	ElementQualified(form string) bool
+	AttributeQualified(form string) bool
 }
 
 type SchemaRepository interface {
 	GetSchema(space string) (Schemaer, error)
 }
 
-func hasPrefix(m map[string]interface{}, prefix string) (ok bool) {
+// isPathPrefix reports whether the param path key equals prefix or lies
+// below it, including indexed paths like "operations[0]/operator" below
+// "operations".
+func isPathPrefix(key, prefix string) bool {
+	return key == prefix || strings.HasPrefix(key, prefix+"/") || strings.HasPrefix(key, prefix+"[")
+}
+
+// indices returns the sorted indices of the indexed paths like
+// "mutate/operations[0]/operator" among the counts of params for the element
+// at key.
+func indices(m map[string]int, key string) (indices []int) {
+	seen := map[int]bool{}
 	for k := range m {
-		ok = strings.HasPrefix(k, prefix)
-		if ok {
-			return
+		if !strings.HasPrefix(k, key+"[") {
+			continue
+		}
+
+		rest := k[len(key)+1:]
+		end := strings.Index(rest, "]")
+		if end < 0 || (end+1 < len(rest) && rest[end+1] != '/') {
+			continue
 		}
+
+		i, err := strconv.Atoi(rest[:end])
+		if err != nil || i < 0 || seen[i] {
+			continue
+		}
+
+		seen[i] = true
+		indices = append(indices, i)
 	}
 
+	sort.Ints(indices)
 	return
 }
 
+// suggest returns the one of names closest to the misspelled name or "" if
+// none is similar enough.
+func suggest(name string, names []string) (s string) {
+	best := len(name)/3 + 1
+	if best < 3 {
+		best = 3
+	}
+
+	for _, n := range names {
+		if d := distance(strings.ToLower(name), strings.ToLower(n)); d < best {
+			s, best = n, d
+		}
+	}
+
+	return
+}
+
+// distance returns the Levenshtein distance of a and b.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d := min3(row[j]+1, row[j-1]+1, prev+cost)
+			prev, row[j] = row[j], d
+		}
+	}
+
+	return row[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
 func MakePath(path []string) string {
 	return strings.Join(path, "/")
 }
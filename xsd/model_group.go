@@ -0,0 +1,397 @@
+package xsd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+)
+
+// ContentModel is the particle of a complex type or an extension, i.e. one
+// of a sequence, a choice, an all or a reference to a group.
+type ContentModel struct {
+	Sequence *ModelGroup `xml:"http://www.w3.org/2001/XMLSchema sequence"`
+	Choice   *ModelGroup `xml:"http://www.w3.org/2001/XMLSchema choice"`
+	All      *ModelGroup `xml:"http://www.w3.org/2001/XMLSchema all"`
+	Group    *GroupRef   `xml:"http://www.w3.org/2001/XMLSchema group"`
+}
+
+// ModelGroup is a sequence, choice or all. Its particles keep the order
+// they are declared in.
+type ModelGroup struct {
+	XMLName   xml.Name
+	MinOccurs string
+	MaxOccurs string
+	Particles []Particle
+}
+
+// Particle is either an element, a nested model group or a group reference.
+type Particle struct {
+	Element  *Element
+	Group    *ModelGroup
+	GroupRef *GroupRef
+}
+
+// GroupRef is a reference to a named group like '<group ref="tns:fields"/>'.
+type GroupRef struct {
+	XMLName   xml.Name `xml:"http://www.w3.org/2001/XMLSchema group"`
+	Ref       string   `xml:"ref,attr"`
+	MinOccurs string   `xml:"minOccurs,attr"`
+	MaxOccurs string   `xml:"maxOccurs,attr"`
+}
+
+// Group is a named group declared in a schema.
+type Group struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2001/XMLSchema group"`
+	Name    string   `xml:"name,attr"`
+	ContentModel
+	// doc is the included document declaring a global group merged into
+	// another schema, see Schema.Merge.
+	doc *Schema
+}
+
+func (self *ModelGroup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err error) {
+	self.XMLName = start.Name
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "minOccurs":
+			self.MinOccurs = attr.Value
+		case "maxOccurs":
+			self.MaxOccurs = attr.Value
+		}
+	}
+
+	for {
+		var t xml.Token
+		t, err = d.Token()
+		if err != nil {
+			return
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			var p Particle
+			if t.Name.Space != XMLSchemaNamespace {
+				err = d.Skip()
+			} else {
+				switch t.Name.Local {
+				case "element":
+					p.Element = new(Element)
+					err = d.DecodeElement(p.Element, &t)
+				case "sequence", "choice", "all":
+					p.Group = new(ModelGroup)
+					err = d.DecodeElement(p.Group, &t)
+				case "group":
+					p.GroupRef = new(GroupRef)
+					err = d.DecodeElement(p.GroupRef, &t)
+				default:
+					err = d.Skip()
+				}
+			}
+			if err != nil {
+				return
+			}
+
+			if p.Element != nil || p.Group != nil || p.GroupRef != nil {
+				self.Particles = append(self.Particles, p)
+			}
+		case xml.EndElement:
+			return
+		}
+	}
+}
+
+// Kind returns 'sequence', 'choice' or 'all'.
+func (self *ModelGroup) Kind() string {
+	return self.XMLName.Local
+}
+
+// Elements returns the elements of the model group and of its nested model
+// groups in the order they are declared. References to named groups are
+// left out, as they need a SchemaRepository to be resolved.
+func (self *ModelGroup) Elements() (elements []Element) {
+	for _, p := range self.Particles {
+		switch {
+		case p.Element != nil:
+			elements = append(elements, *p.Element)
+		case p.Group != nil:
+			elements = append(elements, p.Group.Elements()...)
+		}
+	}
+
+	return
+}
+
+// Elements returns the elements of the sequence, choice or all of the
+// content model, see ModelGroup.Elements.
+func (self *ContentModel) Elements() []Element {
+	for _, g := range []*ModelGroup{self.Sequence, self.Choice, self.All} {
+		if g != nil {
+			return g.Elements()
+		}
+	}
+
+	return nil
+}
+
+func occurs(minOccurs, maxOccurs string) (min, max int, err error) {
+	min, max = 1, 1
+	if minOccurs != "" {
+		min, err = strconv.Atoi(minOccurs)
+		if err != nil {
+			err = fmt.Errorf("malformed minOccurs '%s'", minOccurs)
+			return
+		}
+	}
+
+	switch maxOccurs {
+	case "":
+	case "unbounded":
+		max = -1
+	default:
+		max, err = strconv.Atoi(maxOccurs)
+		if err != nil {
+			err = fmt.Errorf("malformed maxOccurs '%s'", maxOccurs)
+		}
+	}
+
+	return
+}
+
+func (self *ContentModel) Encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
+	p := self.particle()
+	if p == nil {
+		return
+	}
+
+	err = p.encode(enc, sr, ga, params, enc.limit, path...)
+	return
+}
+
+func (self *ContentModel) particle() *Particle {
+	switch {
+	case self.Sequence != nil:
+		return &Particle{Group: self.Sequence}
+	case self.Choice != nil:
+		return &Particle{Group: self.Choice}
+	case self.All != nil:
+		return &Particle{Group: self.All}
+	case self.Group != nil:
+		return &Particle{GroupRef: self.Group}
+	}
+
+	return nil
+}
+
+// resolve returns the model group of the particle along with the schema it
+// is declared in, following group references.
+func (self *Particle) resolve(sr SchemaRepository, ga GetAliaser) (group *ModelGroup, minOccurs, maxOccurs string, gga GetAliaser, err error) {
+	gga = ga
+	if self.Group != nil {
+		group, minOccurs, maxOccurs = self.Group, self.Group.MinOccurs, self.Group.MaxOccurs
+		return
+	}
+
+	var schema *Schema
+	var name string
+	schema, name, err = lookupSchema(self.GroupRef.Ref, sr, ga)
+	if err != nil {
+		return
+	}
+
+	for _, g := range schema.Groups {
+		if g.Name == name {
+			if p := g.particle(); p != nil && p.Group != nil {
+				group, minOccurs, maxOccurs, gga = p.Group, self.GroupRef.MinOccurs, self.GroupRef.MaxOccurs, schema.scope(g.doc)
+				return
+			}
+		}
+	}
+
+	err = fmt.Errorf("did not find group '%s'", self.GroupRef.Ref)
+	return
+}
+
+// names returns the names of all elements the particle may contain.
+func (self *Particle) names(sr SchemaRepository, ga GetAliaser) (names []string, err error) {
+	if self.Element != nil {
+		names = []string{self.Element.Name}
+		return
+	}
+
+	var group *ModelGroup
+	group, _, _, ga, err = self.resolve(sr, ga)
+	if err != nil {
+		return
+	}
+
+	for _, p := range group.Particles {
+		var n []string
+		n, err = p.names(sr, ga)
+		if err != nil {
+			return
+		}
+
+		names = append(names, n...)
+	}
+
+	return
+}
+
+// declare adds the elements the particle may contain to decls. They are
+// repeated if they or any of their groups may occur more than once.
+func (self *Particle) declare(sr SchemaRepository, ga GetAliaser, repeated bool, decls map[string]elementDecl) (err error) {
+	if self.Element != nil {
+		var max int
+		_, max, err = self.Element.Occurs()
+		if err != nil {
+			return
+		}
+
+		decls[self.Element.Name] = elementDecl{self.Element, ga, repeated || max != 1}
+		return
+	}
+
+	var group *ModelGroup
+	var minOccurs, maxOccurs string
+	group, minOccurs, maxOccurs, ga, err = self.resolve(sr, ga)
+	if err != nil {
+		return
+	}
+
+	var max int
+	_, max, err = occurs(minOccurs, maxOccurs)
+	if err != nil {
+		return
+	}
+
+	for _, p := range group.Particles {
+		err = p.declare(sr, ga, repeated || max != 1, decls)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// remaining counts the params left for the particle below path.
+func (self *Particle) remaining(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (n int, err error) {
+	var names []string
+	names, err = self.names(sr, ga)
+	if err != nil {
+		return
+	}
+
+	for _, name := range names {
+		n += enc.remaining(params, MakePath(append(path[:len(path):len(path)], name)))
+	}
+	return
+}
+
+// encode writes the particle. If limit is set, the particle is part of a
+// repeated group and its elements stop at their maxOccurs, leaving further
+// params to the next occurrence of the group.
+func (self *Particle) encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, limit bool, path ...string) (err error) {
+	if self.Element != nil {
+		err = self.Element.encode(enc, sr, ga, params, limit, path...)
+		return
+	}
+
+	var group *ModelGroup
+	var minOccurs, maxOccurs string
+	group, minOccurs, maxOccurs, ga, err = self.resolve(sr, ga)
+	if err != nil {
+		return
+	}
+
+	var min, max int
+	min, max, err = occurs(minOccurs, maxOccurs)
+	if err != nil {
+		return
+	}
+	limit = limit || max != 1
+
+	for n := 0; max < 0 || n < max; n++ {
+		var before int
+		before, err = self.remaining(enc, sr, ga, params, path...)
+		if err != nil {
+			return
+		}
+
+		if before == 0 && n >= min {
+			return
+		}
+
+		err = group.encodeOnce(enc, sr, ga, params, limit, path...)
+		if err != nil {
+			return
+		}
+
+		var after int
+		after, err = self.remaining(enc, sr, ga, params, path...)
+		if err != nil || after == 0 || after >= before {
+			return
+		}
+	}
+
+	return
+}
+
+// encodeOnce writes a single occurrence of the group.
+func (self *ModelGroup) encodeOnce(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, limit bool, path ...string) (err error) {
+	if self.Kind() != "choice" {
+		for _, p := range self.Particles {
+			err = p.encode(enc, sr, ga, params, limit, path...)
+			if err != nil {
+				return
+			}
+		}
+
+		return
+	}
+
+	// a choice takes the particle whose params are given
+	var given []int
+	var names []string
+	for i, p := range self.Particles {
+		var n int
+		n, err = p.remaining(enc, sr, ga, params, path...)
+		if err != nil {
+			return
+		}
+
+		if n > 0 {
+			given = append(given, i)
+		}
+
+		var pn []string
+		pn, err = p.names(sr, ga)
+		if err != nil {
+			return
+		}
+		names = append(names, pn...)
+	}
+
+	switch {
+	case len(given) == 0:
+		enc.Violate(MakePath(path), "requires one of the choices %q", names)
+	case len(given) > 1 && !limit:
+		var ambiguous []string
+		for _, i := range given {
+			var pn []string
+			pn, err = self.Particles[i].names(sr, ga)
+			if err != nil {
+				return
+			}
+
+			for _, name := range pn {
+				ambiguous = append(ambiguous, enc.skip(params, MakePath(append(path[:len(path):len(path)], name)))...)
+			}
+		}
+		enc.Violate(MakePath(path), "ambiguous choice between parameters %q", ambiguous)
+	default:
+		err = self.Particles[given[0]].encode(enc, sr, ga, params, limit, path...)
+	}
+
+	return
+}
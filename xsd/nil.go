@@ -0,0 +1,64 @@
+package xsd
+
+import "encoding/xml"
+
+// Nil is a param value which encodes its element as '<foo xsi:nil="true"/>',
+// e.g. to clear a field. The element must be declared nillable.
+var Nil = &nilValue{}
+
+type nilValue struct{}
+
+// encodeNil adds xsi:nil to start.
+func encodeNil(enc *Encoder, start *xml.StartElement) {
+	attr := xml.Attr{Name: xml.Name{Space: XMLSchemaInstanceNamespace, Local: "nil"}, Value: "true"}
+	if !enc.explicit {
+		// encoding/xml would make up a prefix for xsi itself, which xsi:type
+		// may have declared already
+		var declared bool
+		for _, a := range start.Attr {
+			declared = declared || a.Name.Local == "xmlns:xsi"
+		}
+		if !declared {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: XMLSchemaInstanceNamespace})
+		}
+		attr.Name = xml.Name{Local: "xsi:nil"}
+	}
+
+	start.Attr = append(start.Attr, attr)
+}
+
+// isNil reports whether start has xsi:nil set.
+func isNil(start xml.StartElement) bool {
+	for _, attr := range start.Attr {
+		if attr.Name.Space == XMLSchemaInstanceNamespace && attr.Name.Local == "nil" {
+			return attr.Value == "true" || attr.Value == "1"
+		}
+	}
+
+	return false
+}
+
+// nilReader leaves out elements with xsi:nil set while skip is set.
+type nilReader struct {
+	d    *xml.Decoder
+	skip bool
+}
+
+func (self *nilReader) Token() (t xml.Token, err error) {
+	for {
+		t, err = self.d.Token()
+		if err != nil || !self.skip {
+			return
+		}
+
+		start, ok := t.(xml.StartElement)
+		if !ok || !isNil(start) {
+			return
+		}
+
+		err = self.d.Skip()
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package xsd
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Params returns the param paths of v. A map[string]interface{} is taken as
+// params already, any other value like a struct with xml tags holds the
+// content of the element root. Nested structs and maps give the paths below
+// their field or key, e.g. a field tagged `xml:"fields"` of a struct given
+// for the field `xml:"serviceSelector"` is "get/serviceSelector/fields".
+// Slices of structs or maps repeat the element, each item giving the params
+// of one occurrence like "get/operations[0]/operator".
+func Params(v interface{}, root string) (params map[string]interface{}, err error) {
+	params = map[string]interface{}{}
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, val := range m {
+			err = flatten(params, k, reflect.ValueOf(val))
+			if err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	err = flatten(params, root, reflect.ValueOf(v))
+	return
+}
+
+func flatten(params map[string]interface{}, key string, val reflect.Value) (err error) {
+	for val.Kind() == reflect.Interface || (val.Kind() == reflect.Ptr && !isLeaf(val)) {
+		if val.IsNil() {
+			return
+		}
+		val = val.Elem()
+	}
+
+	if !val.IsValid() {
+		return
+	}
+
+	if isLeaf(val) {
+		params[key] = val.Interface()
+		return
+	}
+
+	switch val.Kind() {
+	case reflect.Struct:
+		err = flattenStruct(params, key, val)
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			err = fmt.Errorf("map of '%s' must have string keys, not %s", key, val.Type().Key())
+			return
+		}
+
+		keys := val.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		for _, k := range keys {
+			err = flatten(params, joinPath(key, k.String()), val.MapIndex(k))
+			if err != nil {
+				return
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if leafItems(val) {
+			params[key] = val.Interface()
+			return
+		}
+
+		for i := 0; i < val.Len(); i++ {
+			err = flatten(params, fmt.Sprintf("%s[%d]", key, i), val.Index(i))
+			if err != nil {
+				return
+			}
+		}
+	default:
+		err = fmt.Errorf("unsupported type %s of '%s'", val.Type(), key)
+	}
+
+	return
+}
+
+func flattenStruct(params map[string]interface{}, key string, val reflect.Value) (err error) {
+	t := val.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name, omitEmpty, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+
+		fv := val.Field(i)
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			// embedded structs add their fields to the struct
+			err = flatten(params, key, fv)
+		} else {
+			err = flatten(params, joinPath(key, name), fv)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// fieldName returns the name of the param of struct field f as given by its
+// xml tag, e.g. "@id" for `xml:"id,attr"` or "" for the chardata of the
+// element itself.
+func fieldName(f reflect.StructField) (name string, omitEmpty, ok bool) {
+	tag := f.Tag.Get("xml")
+	if tag == "-" || f.Name == "XMLName" {
+		return
+	}
+
+	opts := strings.Split(tag, ",")
+	name = opts[0]
+	if i := strings.Index(name, " "); i >= 0 {
+		// 'namespace local' names like the one of xsi:type
+		space, local := name[:i], name[i+1:]
+		name = local
+		if space == XMLSchemaInstanceNamespace {
+			name = "xsi:" + local
+		}
+	}
+
+	if name == "" && !f.Anonymous {
+		name = f.Name
+	}
+	name = strings.Replace(name, ">", "/", -1)
+
+	for _, opt := range opts[1:] {
+		switch opt {
+		case "attr":
+			name = "@" + name
+		case "chardata":
+			name = ""
+		case "omitempty":
+			omitEmpty = true
+		case "innerxml", "comment", "any":
+			return
+		}
+	}
+
+	ok = true
+	return
+}
+
+func joinPath(key, name string) string {
+	switch {
+	case name == "":
+		return key
+	case key == "":
+		return name
+	}
+
+	return key + "/" + name
+}
+
+// isLeaf reports whether val is a value of a simple type rather than one
+// holding further params.
+func isLeaf(val reflect.Value) bool {
+	if !val.IsValid() {
+		return false
+	}
+
+	// e.g. time.Time and *big.Int
+	if v := val.Interface(); v == Nil || isTextMarshaler(v) {
+		return true
+	}
+
+	switch val.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Interface, reflect.Array:
+		return false
+	case reflect.Slice:
+		return val.Type().Elem().Kind() == reflect.Uint8
+	case reflect.Ptr:
+		return !val.IsNil() && isLeaf(val.Elem())
+	}
+
+	return true
+}
+
+// leafItems reports whether all items of the slice val are leaves.
+func leafItems(val reflect.Value) bool {
+	for i := 0; i < val.Len(); i++ {
+		item := val.Index(i)
+		for item.Kind() == reflect.Interface && !item.IsNil() {
+			item = item.Elem()
+		}
+
+		if !isLeaf(item) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,80 @@
+package xsd
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type paramsSelector struct {
+	ID     int      `xml:"id,attr"`
+	Fields []string `xml:"fields"`
+	Label  string   `xml:"label,omitempty"`
+}
+
+type paramsOperation struct {
+	Type     string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	Operator string `xml:"operator"`
+	Amount   struct {
+		Currency string `xml:"currency,attr"`
+		Value    int64  `xml:",chardata"`
+	} `xml:"amount"`
+}
+
+func TestParams(t *testing.T) {
+	Convey("given values for the content of an element", t, func() {
+		cases := []struct {
+			comment  string
+			value    interface{}
+			expected map[string]interface{}
+		}{
+			{
+				comment: "struct with attributes and omitted fields",
+				value: struct {
+					Selector paramsSelector `xml:"serviceSelector"`
+					Paging   *struct{}      `xml:"paging"`
+				}{
+					Selector: paramsSelector{ID: 1, Fields: []string{"Id", "Name"}},
+				},
+				expected: map[string]interface{}{
+					"get/serviceSelector/@id":    1,
+					"get/serviceSelector/fields": []string{"Id", "Name"},
+				},
+			},
+			{
+				comment: "repeated structs with xsi:type and chardata",
+				value: map[string]interface{}{
+					"get/operations": []paramsOperation{{Type: "CampaignOperation", Operator: "ADD"}},
+				},
+				expected: map[string]interface{}{
+					"get/operations[0]/@xsi:type":        "CampaignOperation",
+					"get/operations[0]/operator":         "ADD",
+					"get/operations[0]/amount/@currency": "",
+					"get/operations[0]/amount":           int64(0),
+				},
+			},
+			{
+				comment: "nested maps in path-keyed params",
+				value: map[string]interface{}{
+					"get/serviceSelector": map[string]interface{}{
+						"fields": "Id",
+						"paging": map[string]int{"numberResults": 10},
+					},
+				},
+				expected: map[string]interface{}{
+					"get/serviceSelector/fields":               "Id",
+					"get/serviceSelector/paging/numberResults": 10,
+				},
+			},
+		}
+
+		for _, c := range cases {
+			Convey(fmt.Sprintf("test case '%s'", c.comment), func() {
+				params, err := Params(c.value, "get")
+				So(err, ShouldBeNil)
+				So(fmt.Sprint(params), ShouldEqual, fmt.Sprint(c.expected))
+			})
+		}
+	})
+}
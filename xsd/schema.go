@@ -6,12 +6,29 @@ import (
 )
 
 type InnerSchema struct {
-	TargetNamespace    string        `xml:"targetNamespace,attr"`
-	ElementFormDefault string        `xml:"elementFormDefault,attr"`
-	Version            string        `xml:"version,attr"`
-	ComplexTypes       []ComplexType `xml:"http://www.w3.org/2001/XMLSchema complexType"`
-	SimpleTypes        []SimpleType  `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
-	Elements           []Element     `xml:"http://www.w3.org/2001/XMLSchema element"`
+	TargetNamespace      string           `xml:"targetNamespace,attr"`
+	ElementFormDefault   string           `xml:"elementFormDefault,attr"`
+	AttributeFormDefault string           `xml:"attributeFormDefault,attr"`
+	Version              string           `xml:"version,attr"`
+	ComplexTypes         []ComplexType    `xml:"http://www.w3.org/2001/XMLSchema complexType"`
+	SimpleTypes          []SimpleType     `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+	Elements             []Element        `xml:"http://www.w3.org/2001/XMLSchema element"`
+	Attributes           []Attribute      `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	AttributeGroups      []AttributeGroup `xml:"http://www.w3.org/2001/XMLSchema attributeGroup"`
+	Groups               []Group          `xml:"http://www.w3.org/2001/XMLSchema group"`
+	Imports              []Import         `xml:"http://www.w3.org/2001/XMLSchema import"`
+	Includes             []Include        `xml:"http://www.w3.org/2001/XMLSchema include"`
+}
+
+type Import struct {
+	XMLName        xml.Name `xml:"http://www.w3.org/2001/XMLSchema import"`
+	Namespace      string   `xml:"namespace,attr"`
+	SchemaLocation string   `xml:"schemaLocation,attr"`
+}
+
+type Include struct {
+	XMLName        xml.Name `xml:"http://www.w3.org/2001/XMLSchema include"`
+	SchemaLocation string   `xml:"schemaLocation,attr"`
 }
 
 type Schema struct {
@@ -35,6 +52,68 @@ func (self *Schema) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err er
 	return
 }
 
+// Merge adds the types and elements of other, e.g. an included schema, to
+// the schema. They keep resolving prefixes with the aliases of other and
+// take its form defaults, which may differ from those of the schema.
+func (self *Schema) Merge(other *Schema) {
+	doc := &Schema{XMLName: other.XMLName, Aliases: other.Aliases}
+	doc.TargetNamespace = other.TargetNamespace
+	doc.ElementFormDefault = other.ElementFormDefault
+	doc.AttributeFormDefault = other.AttributeFormDefault
+
+	for _, t := range other.ComplexTypes {
+		if t.doc == nil {
+			t.doc = doc
+		}
+		self.ComplexTypes = append(self.ComplexTypes, t)
+	}
+
+	for _, t := range other.SimpleTypes {
+		if t.doc == nil {
+			t.doc = doc
+		}
+		self.SimpleTypes = append(self.SimpleTypes, t)
+	}
+
+	for _, e := range other.Elements {
+		if e.doc == nil {
+			e.doc = doc
+		}
+		self.Elements = append(self.Elements, e)
+	}
+
+	for _, a := range other.Attributes {
+		if a.doc == nil {
+			a.doc = doc
+		}
+		self.Attributes = append(self.Attributes, a)
+	}
+
+	for _, g := range other.AttributeGroups {
+		if g.doc == nil {
+			g.doc = doc
+		}
+		self.AttributeGroups = append(self.AttributeGroups, g)
+	}
+
+	for _, g := range other.Groups {
+		if g.doc == nil {
+			g.doc = doc
+		}
+		self.Groups = append(self.Groups, g)
+	}
+}
+
+// scope returns the document declaring a global component of the schema,
+// which resolves the prefixes in the component, given the doc it was merged
+// from.
+func (self *Schema) scope(doc *Schema) *Schema {
+	if doc != nil {
+		return doc
+	}
+	return self
+}
+
 func (self *Schema) Namespace() string {
 	return self.TargetNamespace
 }
@@ -43,28 +122,127 @@ func (self *Schema) GetAlias(alias string) (space string) {
 	return self.Aliases[alias]
 }
 
-func (self *Schema) EncodeElement(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) error {
+// ElementQualified reports whether a local element declared with form, or
+// with the elementFormDefault of the schema if form is empty, is in the
+// target namespace.
+func (self *Schema) ElementQualified(form string) bool {
+	if form == "" {
+		form = self.ElementFormDefault
+	}
+	return form == "qualified"
+}
+
+// AttributeQualified reports whether a local attribute declared with form,
+// or with the attributeFormDefault of the schema if form is empty, is in the
+// target namespace.
+func (self *Schema) AttributeQualified(form string) bool {
+	if form == "" {
+		form = self.AttributeFormDefault
+	}
+	return form == "qualified"
+}
+
+func (self *Schema) complexType(name string) *ComplexType {
+	for i := range self.ComplexTypes {
+		if self.ComplexTypes[i].Name == name {
+			return &self.ComplexTypes[i]
+		}
+	}
+
+	return nil
+}
+
+func (self *Schema) EncodeElement(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) error {
 	for _, elem := range self.Elements {
 		if elem.Name == name {
-			return elem.Encode(enc, sr, self, params, path...)
+			// global elements are always qualified
+			elem.Form = "qualified"
+			return elem.Encode(enc, sr, self.scope(elem.doc), params, path...)
 		}
 	}
 
 	return fmt.Errorf("did not find element '%s'", name)
 }
 
-func (self *Schema) EncodeType(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) error {
+func (self *Schema) EncodeType(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) error {
+	for _, cmplx := range self.ComplexTypes {
+		if cmplx.Name == name {
+			return cmplx.Encode(enc, sr, self.scope(cmplx.doc), params, path...)
+		}
+	}
+
+	for _, smpl := range self.SimpleTypes {
+		if smpl.Name == name {
+			return smpl.Encode(enc, sr, self.scope(smpl.doc), params, path...)
+		}
+	}
+
+	return fmt.Errorf("did not find type '%s'", name)
+}
+
+func (self *Schema) EncodeAttributes(name string, enc *Encoder, start *xml.StartElement, sr SchemaRepository, params map[string]interface{}, path ...string) error {
 	for _, cmplx := range self.ComplexTypes {
 		if cmplx.Name == name {
-			return cmplx.Encode(enc, sr, self, params, path...)
+			return cmplx.EncodeAttributes(enc, start, sr, self.scope(cmplx.doc), params, path...)
 		}
 	}
 
 	for _, smpl := range self.SimpleTypes {
 		if smpl.Name == name {
-			return smpl.Encode(enc, sr, self, params, path...)
+			return nil
 		}
 	}
 
 	return fmt.Errorf("did not find type '%s'", name)
 }
+
+func (self *Schema) FormatType(name string, sr SchemaRepository, v interface{}) (string, error) {
+	for _, smpl := range self.SimpleTypes {
+		if smpl.Name == name {
+			return smpl.Format(sr, self.scope(smpl.doc), v)
+		}
+	}
+
+	return "", fmt.Errorf("did not find simple type '%s'", name)
+}
+
+// DecodeElement decodes the global element name opened by start, see
+// Element.Decode.
+func (self *Schema) DecodeElement(name string, dec *Decoder, start xml.StartElement, sr SchemaRepository) (interface{}, error) {
+	for _, elem := range self.Elements {
+		if elem.Name == name {
+			return elem.Decode(dec, start, sr, self.scope(elem.doc))
+		}
+	}
+
+	return nil, fmt.Errorf("did not find element '%s'", name)
+}
+
+func (self *Schema) DecodeType(name string, dec *Decoder, start xml.StartElement, sr SchemaRepository) (interface{}, error) {
+	if cmplx := self.complexType(name); cmplx != nil {
+		return cmplx.Decode(dec, start, sr, self.scope(cmplx.doc))
+	}
+
+	for _, smpl := range self.SimpleTypes {
+		if smpl.Name == name {
+			s, err := dec.text(start)
+			if err != nil {
+				return nil, err
+			}
+
+			return smpl.Parse(sr, self.scope(smpl.doc), s)
+		}
+	}
+
+	return nil, fmt.Errorf("did not find type '%s'", name)
+}
+
+func (self *Schema) ParseType(name string, sr SchemaRepository, s string) (interface{}, error) {
+	for _, smpl := range self.SimpleTypes {
+		if smpl.Name == name {
+			return smpl.Parse(sr, self.scope(smpl.doc), s)
+		}
+	}
+
+	return nil, fmt.Errorf("did not find simple type '%s'", name)
+}
@@ -22,6 +22,18 @@ func (self SchemaMap) GetSchema(space string) (s Schemaer, err error) {
 	return
 }
 
+// Add adds schema to the map, merging it into an already present schema of
+// the same target namespace.
+func (self SchemaMap) Add(schema Schema) {
+	if s, ok := self[schema.TargetNamespace]; ok {
+		s.Merge(&schema)
+		self[schema.TargetNamespace] = s
+		return
+	}
+
+	self[schema.TargetNamespace] = schema
+}
+
 func (self SchemaMap) GetElement(space, name string) *Element {
 	schema, ok := self[space]
 	if !ok {
@@ -0,0 +1,987 @@
+package xsd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type schemaTestCase struct {
+	comment        string
+	schema         string
+	imports        []string
+	prefixes       map[string]string
+	lenient        bool
+	element        string
+	params         map[string]interface{}
+	isFaulty       bool
+	expectedError  string
+	expectedResult string
+}
+
+var (
+	schemaTestCases = []schemaTestCase{
+		{
+			comment: "attributes of a complex type",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:complexType name="Selector">
+    <xsd:sequence><xsd:element name="field" type="xsd:string"/></xsd:sequence>
+    <xsd:attribute name="id" type="xsd:int" use="required"/>
+    <xsd:attribute name="version" type="xsd:string" fixed="v1"/>
+    <xsd:attribute name="label" type="xsd:string" default="none"/>
+  </xsd:complexType>
+  <xsd:element name="get" type="tns:Selector"/>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/@id":   1,
+				"get/field": "name",
+			},
+			expectedResult: `<get xmlns="urn:test" id="1" version="v1"><field xmlns="urn:test">name</field></get>`,
+		},
+		{
+			comment: "invalid attributes",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:complexType name="Selector">
+    <xsd:sequence><xsd:element name="field" type="xsd:string"/></xsd:sequence>
+    <xsd:attribute name="id" type="xsd:int" use="required"/>
+    <xsd:attribute name="version" type="xsd:string" fixed="v1"/>
+    <xsd:attribute name="secret" type="xsd:string" use="prohibited"/>
+    <xsd:attribute name="code">
+      <xsd:simpleType><xsd:restriction base="xsd:string"><xsd:maxLength value="2"/></xsd:restriction></xsd:simpleType>
+    </xsd:attribute>
+  </xsd:complexType>
+  <xsd:element name="get" type="tns:Selector"/>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/@version": "v2",
+				"get/@secret":  "s3cr3t",
+				"get/@code":    "abc",
+			},
+			isFaulty:      true,
+			expectedError: `invalid parameters: 'get/@id': missing required attribute 'id'; 'get/@version': have 'v2', want fixed value 'v1'; 'get/@secret': prohibited attribute 'secret' must not be set; 'get/@code': value 'abc' violates facet maxLength 2; 'get/field': occurs 0 times, want at least minOccurs 1`,
+		},
+		{
+			comment: "attribute groups and simple content",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:attributeGroup name="versioned">
+    <xsd:attribute name="version" type="xsd:int"/>
+  </xsd:attributeGroup>
+  <xsd:complexType name="Money">
+    <xsd:simpleContent>
+      <xsd:extension base="xsd:long">
+        <xsd:attribute name="currency" type="xsd:string"/>
+        <xsd:attributeGroup ref="tns:versioned"/>
+      </xsd:extension>
+    </xsd:simpleContent>
+  </xsd:complexType>
+  <xsd:element name="get">
+    <xsd:complexType>
+      <xsd:sequence><xsd:element name="amount" type="tns:Money" maxOccurs="unbounded"/></xsd:sequence>
+    </xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/amount":           []int{100, 200},
+				"get/amount/@currency": []string{"EUR", "USD"},
+				"get/amount/@version":  2,
+			},
+			expectedResult: `<get xmlns="urn:test"><amount xmlns="urn:test" currency="EUR" version="2">100</amount><amount xmlns="urn:test" currency="USD">200</amount></get>`,
+		},
+		{
+			comment: "cardinality violations",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:complexType name="Selector">
+    <xsd:sequence>
+      <xsd:element name="fields" type="xsd:string" maxOccurs="2"/>
+      <xsd:element name="paging" minOccurs="0">
+        <xsd:complexType><xsd:sequence>
+          <xsd:element name="startIndex" type="xsd:int"/>
+          <xsd:element name="numberResults" type="xsd:int"/>
+        </xsd:sequence></xsd:complexType>
+      </xsd:element>
+    </xsd:sequence>
+  </xsd:complexType>
+  <xsd:element name="get">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="selector" type="tns:Selector"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/selector/fields":            []string{"Id", "Name", "Status"},
+				"get/selector/paging/startIndex": 0,
+			},
+			isFaulty:      true,
+			expectedError: `invalid parameters: 'get/selector/fields': occurs more than maxOccurs 2 times; 'get/selector/paging/numberResults': occurs 0 times, want at least minOccurs 1`,
+		},
+		{
+			comment: "unbounded elements and empty slices",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:element name="get">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="fields" type="xsd:string" maxOccurs="unbounded"/>
+      <xsd:element name="ids" type="xsd:long" minOccurs="0" maxOccurs="unbounded"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/fields": []string{"Id", "Name", "Status"},
+				"get/ids":    []int64{},
+			},
+			expectedResult: `<get xmlns="urn:test"><fields xmlns="urn:test">Id</fields><fields xmlns="urn:test">Name</fields><fields xmlns="urn:test">Status</fields></get>`,
+		},
+		{
+			comment: "parameters not matching the schema",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:element name="get">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="fields" type="xsd:string" minOccurs="0" maxOccurs="unbounded"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/field": "Id",
+			},
+			isFaulty:      true,
+			expectedError: `invalid parameters: 'get/field': matches nothing in the schema, did you mean 'get/fields'?`,
+		},
+		{
+			comment: "misspelled path below a complex type",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:complexType name="Selector">
+    <xsd:sequence><xsd:element name="fields" type="xsd:string" minOccurs="0" maxOccurs="unbounded"/></xsd:sequence>
+    <xsd:attribute name="id" type="xsd:int"/>
+  </xsd:complexType>
+  <xsd:element name="get">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="serviceSelector" type="tns:Selector" minOccurs="0"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/serviceSelecter/fields": "Id",
+				"get/serviceSelector/@ID":    1,
+				"get/unrelated":              true,
+			},
+			isFaulty:      true,
+			expectedError: `invalid parameters: 'get/serviceSelector/@ID': matches nothing in the schema, did you mean 'get/serviceSelector/@id'?; 'get/serviceSelecter/fields': matches nothing in the schema, did you mean 'get/serviceSelector/fields'?; 'get/unrelated': matches nothing in the schema`,
+		},
+		{
+			comment: "nillable element set to nil",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:complexType name="Budget">
+    <xsd:sequence><xsd:element name="amount" type="xsd:long"/></xsd:sequence>
+  </xsd:complexType>
+  <xsd:element name="set">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="name" type="xsd:string" nillable="true"/>
+      <xsd:element name="budget" type="tns:Budget" nillable="true"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			prefixes: map[string]string{"urn:test": "tns", XMLSchemaInstanceNamespace: "xsi"},
+			element:  "set",
+			params: map[string]interface{}{
+				"set/name":   Nil,
+				"set/budget": Nil,
+			},
+			expectedResult: `<tns:set><tns:name xsi:nil="true"></tns:name><tns:budget xsi:nil="true"></tns:budget></tns:set>`,
+		},
+		{
+			comment: "nil for elements which are not nillable",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:element name="set">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="name" type="xsd:string"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "set",
+			params: map[string]interface{}{
+				"set/name": Nil,
+			},
+			isFaulty:      true,
+			expectedError: `invalid parameters: 'set/name': element 'name' is not nillable`,
+		},
+		{
+			comment: "encoder which is not strict ignoring params matching nothing",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:element name="get">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="fields" type="xsd:string" minOccurs="0" maxOccurs="unbounded"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			lenient: true,
+			element: "get",
+			params: map[string]interface{}{
+				"get/fields": "Id",
+				"get/field":  "Name",
+			},
+			expectedResult: `<get xmlns="urn:test"><fields xmlns="urn:test">Id</fields></get>`,
+		},
+		{
+			comment: "choice picked by the given params",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:element name="get">
+    <xsd:complexType><xsd:sequence>
+      <xsd:choice>
+        <xsd:element name="id" type="xsd:long"/>
+        <xsd:element name="name" type="xsd:string"/>
+      </xsd:choice>
+      <xsd:element name="fields" type="xsd:string" minOccurs="0"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/name":   "test",
+				"get/fields": "Id",
+			},
+			expectedResult: `<get xmlns="urn:test"><name xmlns="urn:test">test</name><fields xmlns="urn:test">Id</fields></get>`,
+		},
+		{
+			comment: "ambiguous choice",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:element name="get">
+    <xsd:complexType><xsd:choice>
+      <xsd:element name="id" type="xsd:long"/>
+      <xsd:element name="name" type="xsd:string"/>
+    </xsd:choice></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/id":   1,
+				"get/name": "test",
+			},
+			isFaulty:      true,
+			expectedError: `invalid parameters: 'get': ambiguous choice between parameters ["get/id" "get/name"]`,
+		},
+		{
+			comment: "group references, all and repeated sequences",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:group name="paging">
+    <xsd:all>
+      <xsd:element name="numberResults" type="xsd:int"/>
+      <xsd:element name="startIndex" type="xsd:int" minOccurs="0"/>
+    </xsd:all>
+  </xsd:group>
+  <xsd:element name="get">
+    <xsd:complexType><xsd:sequence>
+      <xsd:sequence minOccurs="0" maxOccurs="unbounded">
+        <xsd:element name="key" type="xsd:string"/>
+        <xsd:element name="value" type="xsd:string"/>
+      </xsd:sequence>
+      <xsd:group ref="tns:paging"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/key":           []string{"a", "b"},
+				"get/value":         []string{"1", "2"},
+				"get/numberResults": 10,
+			},
+			expectedResult: `<get xmlns="urn:test"><key xmlns="urn:test">a</key><value xmlns="urn:test">1</value><key xmlns="urn:test">b</key><value xmlns="urn:test">2</value><numberResults xmlns="urn:test">10</numberResults></get>`,
+		},
+		{
+			comment: "repeated choice",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:element name="get">
+    <xsd:complexType><xsd:choice maxOccurs="unbounded">
+      <xsd:element name="id" type="xsd:long"/>
+      <xsd:element name="name" type="xsd:string"/>
+    </xsd:choice></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/id":   []int{1, 2},
+				"get/name": "test",
+			},
+			expectedResult: `<get xmlns="urn:test"><id xmlns="urn:test">1</id><id xmlns="urn:test">2</id><name xmlns="urn:test">test</name></get>`,
+		},
+		{
+			comment: "derived types with xsi:type",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:complexType name="Operation" abstract="true">
+    <xsd:sequence><xsd:element name="operator" type="xsd:string"/></xsd:sequence>
+  </xsd:complexType>
+  <xsd:complexType name="CampaignOperation">
+    <xsd:complexContent><xsd:extension base="tns:Operation">
+      <xsd:sequence><xsd:element name="operand" type="xsd:long"/></xsd:sequence>
+    </xsd:extension></xsd:complexContent>
+  </xsd:complexType>
+  <xsd:complexType name="Selector">
+    <xsd:sequence><xsd:element name="fields" type="xsd:string"/></xsd:sequence>
+  </xsd:complexType>
+  <xsd:element name="mutate">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="operations" type="tns:Operation" maxOccurs="unbounded"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "mutate",
+			params: map[string]interface{}{
+				"mutate/operations/@xsi:type": []string{"CampaignOperation", "{urn:test}CampaignOperation"},
+				"mutate/operations/operator":  []string{"ADD", "REMOVE"},
+				"mutate/operations/operand":   []int{1, 2},
+			},
+			expectedResult: `<mutate xmlns="urn:test">` +
+				`<operations xmlns="urn:test" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:ns1="urn:test" xsi:type="ns1:CampaignOperation"><operator xmlns="urn:test">ADD</operator><operand xmlns="urn:test">1</operand></operations>` +
+				`<operations xmlns="urn:test" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:ns1="urn:test" xsi:type="ns1:CampaignOperation"><operator xmlns="urn:test">REMOVE</operator><operand xmlns="urn:test">2</operand></operations>` +
+				`</mutate>`,
+		},
+		{
+			comment: "abstract and unrelated types",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:complexType name="Operation" abstract="true">
+    <xsd:sequence><xsd:element name="operator" type="xsd:string"/></xsd:sequence>
+  </xsd:complexType>
+  <xsd:complexType name="CampaignOperation">
+    <xsd:complexContent><xsd:extension base="tns:Operation">
+      <xsd:sequence><xsd:element name="operand" type="xsd:long"/></xsd:sequence>
+    </xsd:extension></xsd:complexContent>
+  </xsd:complexType>
+  <xsd:complexType name="Selector">
+    <xsd:sequence><xsd:element name="fields" type="xsd:string"/></xsd:sequence>
+  </xsd:complexType>
+  <xsd:element name="mutate">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="operations" type="tns:Operation" maxOccurs="unbounded"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "mutate",
+			params: map[string]interface{}{
+				"mutate/operations/@xsi:type": []string{"", "Selector"},
+				"mutate/operations/operator":  []string{"ADD", "REMOVE"},
+			},
+			isFaulty:      true,
+			expectedError: `invalid parameters: 'mutate/operations/@xsi:type': did not find complex type '' in namespace 'urn:test'; 'mutate/operations/@xsi:type': type 'Selector' does not derive from 'Operation'`,
+		},
+		{
+			comment: "restriction facets",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:simpleType name="Status">
+    <xsd:restriction base="xsd:string">
+      <xsd:enumeration value="ENABLE"/>
+      <xsd:enumeration value="DISABLE"/>
+    </xsd:restriction>
+  </xsd:simpleType>
+  <xsd:simpleType name="Code">
+    <xsd:restriction base="xsd:string">
+      <xsd:whiteSpace value="collapse"/>
+      <xsd:pattern value="[A-Z]{2}"/>
+      <xsd:pattern value="[A-Z]{3}"/>
+    </xsd:restriction>
+  </xsd:simpleType>
+  <xsd:simpleType name="Percent">
+    <xsd:restriction base="xsd:decimal">
+      <xsd:minInclusive value="0"/>
+      <xsd:maxInclusive value="100"/>
+      <xsd:fractionDigits value="2"/>
+    </xsd:restriction>
+  </xsd:simpleType>
+  <xsd:element name="set">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="status" type="tns:Status"/>
+      <xsd:element name="code" type="tns:Code"/>
+      <xsd:element name="share" type="tns:Percent"/>
+      <xsd:element name="name">
+        <xsd:simpleType><xsd:restriction base="xsd:string"><xsd:maxLength value="5"/></xsd:restriction></xsd:simpleType>
+      </xsd:element>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "set",
+			params: map[string]interface{}{
+				"set/status": "ENABLE",
+				"set/code":   " DEU ",
+				"set/share":  12.5,
+				"set/name":   "abc",
+			},
+			expectedResult: `<set xmlns="urn:test"><status xmlns="urn:test">ENABLE</status><code xmlns="urn:test">DEU</code><share xmlns="urn:test">12.5</share><name xmlns="urn:test">abc</name></set>`,
+		},
+		{
+			comment: "violated restriction facets",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:simpleType name="Status">
+    <xsd:restriction base="xsd:string">
+      <xsd:enumeration value="ENABLE"/>
+      <xsd:enumeration value="DISABLE"/>
+    </xsd:restriction>
+  </xsd:simpleType>
+  <xsd:simpleType name="Percent">
+    <xsd:restriction base="xsd:decimal">
+      <xsd:minInclusive value="0"/>
+      <xsd:maxInclusive value="100"/>
+      <xsd:fractionDigits value="2"/>
+    </xsd:restriction>
+  </xsd:simpleType>
+  <xsd:simpleType name="SmallPercent">
+    <xsd:restriction base="tns:Percent">
+      <xsd:maxExclusive value="10"/>
+    </xsd:restriction>
+  </xsd:simpleType>
+  <xsd:element name="set">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="status" type="tns:Status"/>
+      <xsd:element name="share" type="tns:Percent" maxOccurs="unbounded"/>
+      <xsd:element name="small" type="tns:SmallPercent"/>
+      <xsd:element name="name">
+        <xsd:simpleType><xsd:restriction base="xsd:string"><xsd:maxLength value="5"/></xsd:restriction></xsd:simpleType>
+      </xsd:element>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "set",
+			params: map[string]interface{}{
+				"set/status": "ENABLED",
+				"set/share":  []float64{101, 1.125},
+				"set/small":  10,
+				"set/name":   "abcdef",
+			},
+			isFaulty:      true,
+			expectedError: `invalid parameters: 'set/status': value 'ENABLED' violates facet enumeration ["ENABLE" "DISABLE"]; 'set/share': value '101' violates facet maxInclusive 100; 'set/share': value '1.125' violates facet fractionDigits 2; 'set/small': value '10' violates facet maxExclusive 10; 'set/name': value 'abcdef' violates facet maxLength 5`,
+		},
+		{
+			comment: "list and union types",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:simpleType name="Ids">
+    <xsd:list itemType="xsd:long"/>
+  </xsd:simpleType>
+  <xsd:simpleType name="ShortIds">
+    <xsd:restriction base="tns:Ids"><xsd:maxLength value="2"/></xsd:restriction>
+  </xsd:simpleType>
+  <xsd:simpleType name="Size">
+    <xsd:union memberTypes="xsd:int">
+      <xsd:simpleType>
+        <xsd:restriction base="xsd:string">
+          <xsd:enumeration value="small"/>
+          <xsd:enumeration value="large"/>
+        </xsd:restriction>
+      </xsd:simpleType>
+    </xsd:union>
+  </xsd:simpleType>
+  <xsd:element name="get">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="ids" type="tns:Ids"/>
+      <xsd:element name="pairs" type="tns:ShortIds" maxOccurs="unbounded"/>
+      <xsd:element name="tags">
+        <xsd:simpleType><xsd:list><xsd:simpleType><xsd:restriction base="xsd:token"/></xsd:simpleType></xsd:list></xsd:simpleType>
+      </xsd:element>
+      <xsd:element name="size" type="tns:Size" maxOccurs="unbounded"/>
+    </xsd:sequence>
+    <xsd:attribute name="ids" type="tns:Ids"/>
+    <xsd:attribute name="tags">
+      <xsd:simpleType><xsd:list itemType="xsd:token"/></xsd:simpleType>
+    </xsd:attribute>
+    </xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/@ids":  []int64{4, 5},
+				"get/@tags": []string{"x", "y"},
+				"get/ids":   []int64{1, 2, 3},
+				"get/pairs": [][]int{{1, 2}, {3}},
+				"get/tags":  List{"a", "b"},
+				"get/size":  []interface{}{"large", 12},
+			},
+			expectedResult: `<get xmlns="urn:test" ids="4 5" tags="x y"><ids xmlns="urn:test">1 2 3</ids><pairs xmlns="urn:test">1 2</pairs><pairs xmlns="urn:test">3</pairs><tags xmlns="urn:test">a b</tags><size xmlns="urn:test">large</size><size xmlns="urn:test">12</size></get>`,
+		},
+		{
+			comment: "invalid list and union values",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:simpleType name="Ids">
+    <xsd:list itemType="xsd:long"/>
+  </xsd:simpleType>
+  <xsd:simpleType name="Pair">
+    <xsd:restriction base="tns:Ids"><xsd:length value="2"/></xsd:restriction>
+  </xsd:simpleType>
+  <xsd:simpleType name="Size">
+    <xsd:union memberTypes="xsd:int xsd:boolean"/>
+  </xsd:simpleType>
+  <xsd:element name="get">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="pair" type="tns:Pair"/>
+      <xsd:element name="size" type="tns:Size"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/pair": "1 2 3",
+				"get/size": "large",
+			},
+			isFaulty:      true,
+			expectedError: `invalid parameters: 'get/pair': value '1 2 3' violates facet length 2; 'get/size': value 'large' violates facet memberTypes ["xsd:int" "xsd:boolean"]`,
+		},
+		{
+			comment: "element and attribute qualification",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" xmlns:o="urn:other" targetNamespace="urn:test" attributeFormDefault="qualified">
+  <xsd:import namespace="urn:other"/>
+  <xsd:element name="get">
+    <xsd:complexType>
+      <xsd:sequence>
+        <xsd:element name="id" type="xsd:int"/>
+        <xsd:element name="name" type="xsd:string" form="qualified"/>
+        <xsd:element name="address" type="o:Address"/>
+      </xsd:sequence>
+      <xsd:attribute name="version" type="xsd:int"/>
+      <xsd:attribute name="lang" type="xsd:string" form="unqualified"/>
+    </xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			imports: []string{`<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:other" elementFormDefault="qualified">
+  <xsd:complexType name="Address">
+    <xsd:sequence><xsd:element name="city" type="xsd:string"/></xsd:sequence>
+    <xsd:attribute name="primary" type="xsd:boolean"/>
+  </xsd:complexType>
+</xsd:schema>`},
+			element: "get",
+			params: map[string]interface{}{
+				"get/@version":         2,
+				"get/@lang":            "en",
+				"get/id":               1,
+				"get/name":             "name",
+				"get/address/@primary": true,
+				"get/address/city":     "Berlin",
+			},
+			expectedResult: `<get xmlns="urn:test" xmlns:_="urn:test" _:version="2" lang="en"><id xmlns="">1</id><name xmlns="urn:test">name</name><address xmlns="" primary="true">` +
+				`<city xmlns="urn:other">Berlin</city></address></get>`,
+		},
+		{
+			comment: "explicit prefixes",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" xmlns:o="urn:other" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:complexType name="Operation">
+    <xsd:sequence><xsd:element name="operator" type="xsd:string"/></xsd:sequence>
+  </xsd:complexType>
+  <xsd:complexType name="CampaignOperation">
+    <xsd:complexContent><xsd:extension base="tns:Operation">
+      <xsd:sequence><xsd:element name="operand" type="o:Campaign"/></xsd:sequence>
+    </xsd:extension></xsd:complexContent>
+  </xsd:complexType>
+  <xsd:element name="mutate">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="operations" type="tns:Operation"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			imports: []string{`<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:other" elementFormDefault="qualified">
+  <xsd:complexType name="Campaign">
+    <xsd:sequence><xsd:element name="id" type="xsd:long"/></xsd:sequence>
+    <xsd:attribute name="lang" type="xsd:string" form="qualified"/>
+  </xsd:complexType>
+</xsd:schema>`},
+			prefixes: map[string]string{
+				"urn:test":                 "tns",
+				XMLSchemaInstanceNamespace: "xsi",
+			},
+			element: "mutate",
+			params: map[string]interface{}{
+				"mutate/operations/@xsi:type":     "CampaignOperation",
+				"mutate/operations/operator":      "ADD",
+				"mutate/operations/operand/@lang": "en",
+				"mutate/operations/operand/id":    1,
+			},
+			expectedResult: `<tns:mutate><tns:operations xsi:type="tns:CampaignOperation"><tns:operator>ADD</tns:operator>` +
+				`<tns:operand ns1:lang="en"><ns1:id>1</ns1:id></tns:operand></tns:operations></tns:mutate>`,
+		},
+		{
+			comment: "params matching nothing in the schema",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:element name="get">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="id" type="xsd:int"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "get",
+			params: map[string]interface{}{
+				"get/id":    []int{1, 2},
+				"get/bogus": "x",
+			},
+			isFaulty:      true,
+			expectedError: `invalid parameters: 'get/id': occurs more than maxOccurs 1 times; 'get/bogus': matches nothing in the schema`,
+		},
+		{
+			comment: "indexed paths and slices of maps",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:complexType name="Operand">
+    <xsd:sequence>
+      <xsd:element name="id" type="xsd:long"/>
+      <xsd:element name="name" type="xsd:string" minOccurs="0"/>
+    </xsd:sequence>
+  </xsd:complexType>
+  <xsd:complexType name="Operation">
+    <xsd:sequence>
+      <xsd:element name="operator" type="xsd:string"/>
+      <xsd:element name="operand" type="tns:Operand" maxOccurs="unbounded"/>
+    </xsd:sequence>
+  </xsd:complexType>
+  <xsd:element name="mutate">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="operations" type="tns:Operation" maxOccurs="unbounded"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "mutate",
+			params: map[string]interface{}{
+				"mutate/operations[1]/operator": "REMOVE",
+				"mutate/operations[1]/operand": []map[string]interface{}{
+					{"id": 2},
+					{"id": 3, "name": "three"},
+				},
+				"mutate/operations[0]/operator":        "ADD",
+				"mutate/operations[0]/operand[0]/name": "one",
+				"mutate/operations[0]/operand[0]/id":   1,
+			},
+			expectedResult: `<mutate xmlns="urn:test"><operations xmlns="urn:test"><operator xmlns="urn:test">ADD</operator><operand xmlns="urn:test"><id xmlns="urn:test">1</id><name xmlns="urn:test">one</name></operand></operations>` +
+				`<operations xmlns="urn:test"><operator xmlns="urn:test">REMOVE</operator><operand xmlns="urn:test"><id xmlns="urn:test">2</id></operand><operand xmlns="urn:test"><id xmlns="urn:test">3</id><name xmlns="urn:test">three</name></operand></operations></mutate>`,
+		},
+		{
+			comment: "included schema of the same namespace",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:element name="get" type="tns:Selector"/>
+</xsd:schema>`,
+			imports: []string{`<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:t="urn:test" xmlns:tns="urn:other" targetNamespace="urn:test">
+  <xsd:simpleType name="Code"><xsd:restriction base="xsd:string"><xsd:maxLength value="2"/></xsd:restriction></xsd:simpleType>
+  <xsd:complexType name="Selector"><xsd:sequence><xsd:element name="code" type="t:Code"/></xsd:sequence></xsd:complexType>
+</xsd:schema>`},
+			element:        "get",
+			params:         map[string]interface{}{"get/code": "de"},
+			expectedResult: `<get xmlns="urn:test"><code xmlns="">de</code></get>`,
+		},
+		{
+			comment: "invalid indexed paths",
+			schema: `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:element name="mutate">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="operations" maxOccurs="2">
+        <xsd:complexType><xsd:sequence>
+          <xsd:element name="operator" type="xsd:string"/>
+        </xsd:sequence></xsd:complexType>
+      </xsd:element>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`,
+			element: "mutate",
+			params: map[string]interface{}{
+				"mutate/operations[0]/operator": "ADD",
+				"mutate/operations[0]/operand":  1,
+				"mutate/operations[1]/operator": "ADD",
+				"mutate/operations[2]/operator": "ADD",
+				"mutate/operations/operator":    "ADD",
+			},
+			isFaulty:      true,
+			expectedError: `invalid parameters: 'mutate/operations[0]/operand': matches nothing in the schema; 'mutate/operations': occurs 4 times, want at most maxOccurs 2`,
+		},
+	}
+)
+
+func TestSchema_EncodeElementReadOnly(t *testing.T) {
+	Convey("given params encoded several times", t, func() {
+		var s Schema
+		err := xml.Unmarshal([]byte(`<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:test">
+  <xsd:element name="get">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="fields" type="xsd:string" maxOccurs="unbounded"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`), &s)
+		So(err, ShouldBeNil)
+
+		sr := SchemaMap{}
+		sr.Add(s)
+
+		params := map[string]interface{}{
+			"get/fields": []string{"Id", "Name"},
+			"other/id":   1,
+		}
+
+		var results []string
+		for i := 0; i < 2; i++ {
+			buf := new(bytes.Buffer)
+			enc := NewEncoder(buf)
+			err = s.EncodeElement("get", enc, sr, params)
+			So(err, ShouldBeNil)
+			So(enc.Err(), ShouldBeNil)
+
+			err = enc.Leftover(params)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, `leftover parameters ["other/id"]`)
+
+			err = enc.Flush()
+			So(err, ShouldBeNil)
+			results = append(results, buf.String())
+		}
+
+		So(len(params), ShouldEqual, 2)
+		So(results[1], ShouldEqual, results[0])
+		So(results[0], ShouldEqual, `<get xmlns="urn:test"><fields xmlns="">Id</fields><fields xmlns="">Name</fields></get>`)
+	})
+}
+
+func TestList_UnmarshalText(t *testing.T) {
+	Convey("given a response with a list value", t, func() {
+		var res struct {
+			Ids List `xml:"ids"`
+		}
+		err := xml.Unmarshal([]byte("<get><ids> 1 2\n3 </ids></get>"), &res)
+		So(err, ShouldBeNil)
+		So(fmt.Sprint(res.Ids), ShouldEqual, "[1 2 3]")
+	})
+}
+
+func TestNewDecoder(t *testing.T) {
+	Convey("given a response with nil elements", t, func() {
+		var res struct {
+			Name   *string `xml:"name"`
+			Amount *int64  `xml:"budget>amount"`
+			Id     *int64  `xml:"id"`
+		}
+		err := NewDecoder(strings.NewReader(`<get xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><name xsi:nil="true"/><budget xsi:nil="1"><amount>1</amount></budget><id>2</id></get>`)).Decode(&res)
+		So(err, ShouldBeNil)
+		So(res.Name, ShouldBeNil)
+		So(res.Amount, ShouldBeNil)
+		So(*res.Id, ShouldEqual, int64(2))
+	})
+}
+
+func TestSchema_DecodeElement(t *testing.T) {
+	Convey("given a schema and a response", t, func() {
+		var s Schema
+		err := xml.Unmarshal([]byte(`<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+  <xsd:simpleType name="Ids"><xsd:list itemType="xsd:long"/></xsd:simpleType>
+  <xsd:complexType name="Money">
+    <xsd:simpleContent><xsd:extension base="xsd:decimal">
+      <xsd:attribute name="currency" type="xsd:string"/>
+    </xsd:extension></xsd:simpleContent>
+  </xsd:complexType>
+  <xsd:complexType name="Entry">
+    <xsd:sequence>
+      <xsd:element name="id" type="xsd:long"/>
+      <xsd:element name="active" type="xsd:boolean"/>
+    </xsd:sequence>
+    <xsd:attribute name="rank" type="xsd:int"/>
+  </xsd:complexType>
+  <xsd:complexType name="Campaign">
+    <xsd:complexContent><xsd:extension base="tns:Entry">
+      <xsd:sequence><xsd:element name="budget" type="tns:Money"/></xsd:sequence>
+    </xsd:extension></xsd:complexContent>
+  </xsd:complexType>
+  <xsd:element name="getResponse">
+    <xsd:complexType><xsd:sequence>
+      <xsd:element name="total" type="xsd:int"/>
+      <xsd:element name="updated" type="xsd:dateTime"/>
+      <xsd:element name="ids" type="tns:Ids"/>
+      <xsd:element name="entries" type="tns:Entry" maxOccurs="unbounded"/>
+      <xsd:element name="comment" type="xsd:string" nillable="true"/>
+    </xsd:sequence></xsd:complexType>
+  </xsd:element>
+</xsd:schema>`), &s)
+		So(err, ShouldBeNil)
+
+		sr := SchemaMap{}
+		sr.Add(s)
+
+		dec := NewDecoder(strings.NewReader(`<getResponse xmlns="urn:test" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:t="urn:test">
+  <total>2</total>
+  <updated>2016-01-02T03:04:05Z</updated>
+  <ids>1 2</ids>
+  <entries rank="1"><id>3</id><active>true</active><extra>x</extra><extra>y</extra></entries>
+  <entries xsi:type="t:Campaign"><id>4</id><active>0</active><budget currency="EUR">1.50</budget></entries>
+  <comment xsi:nil="true"/>
+  <note xsi:nil="true"/>
+</getResponse>`))
+		t, err := dec.Token()
+		So(err, ShouldBeNil)
+
+		v, err := s.DecodeElement("getResponse", dec, t.(xml.StartElement), sr)
+		So(err, ShouldBeNil)
+
+		m := v.(map[string]interface{})
+		So(m["total"], ShouldEqual, int64(2))
+		So(m["updated"], ShouldEqual, time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC))
+		So(fmt.Sprint(m["ids"]), ShouldEqual, "[1 2]")
+
+		for _, name := range []string{"comment", "note"} {
+			nv, ok := m[name]
+			So(ok, ShouldBeTrue)
+			So(nv, ShouldBeNil)
+		}
+
+		entries := m["entries"].([]interface{})
+		So(len(entries), ShouldEqual, 2)
+		So(fmt.Sprint(entries[0]), ShouldEqual, "map[@rank:1 active:true extra:[x y] id:3]")
+
+		campaign := entries[1].(map[string]interface{})
+		So(campaign["@xsi:type"], ShouldEqual, "{urn:test}Campaign")
+		So(campaign["active"], ShouldEqual, false)
+
+		budget := campaign["budget"].(map[string]interface{})
+		So(budget["@currency"], ShouldEqual, "EUR")
+		So(budget[""].(*big.Rat).FloatString(2), ShouldEqual, "1.50")
+	})
+}
+
+func TestSchema_EncodeElement(t *testing.T) {
+	Convey("given a schema and a SchemaRepository", t, func() {
+		for _, c := range schemaTestCases {
+			Convey(fmt.Sprintf("test case '%s'", c.comment), func() {
+				var s Schema
+				err := xml.Unmarshal([]byte(c.schema), &s)
+				So(err, ShouldBeNil)
+
+				sr := SchemaMap{}
+				sr.Add(s)
+				for _, i := range c.imports {
+					var imported Schema
+					err = xml.Unmarshal([]byte(i), &imported)
+					So(err, ShouldBeNil)
+					sr.Add(imported)
+				}
+
+				buf := new(bytes.Buffer)
+				enc := NewEncoder(buf)
+				enc.Strict = !c.lenient
+				if c.prefixes != nil {
+					enc.SetPrefixes(c.prefixes)
+				}
+				params, err := Params(c.params, c.element)
+				So(err, ShouldBeNil)
+
+				err = s.EncodeElement(c.element, enc, sr, params)
+				if err == nil {
+					err = enc.Err()
+				}
+
+				if c.isFaulty {
+					So(err, ShouldNotBeNil)
+					if c.expectedError != "" {
+						So(err.Error(), ShouldEqual, c.expectedError)
+					}
+				} else {
+					So(err, ShouldBeNil)
+					err = enc.Flush()
+					So(err, ShouldBeNil)
+					So(buf.String(), ShouldEqual, c.expectedResult)
+				}
+			})
+		}
+	})
+}
+
+type parseTestCase struct {
+	value          string
+	isFaulty       bool
+	expectedResult interface{}
+}
+
+var parseTestCases = []parseTestCase{
+	{value: "12", expectedResult: int64(12)},
+	{value: "large", expectedResult: "large"},
+	{value: "123", isFaulty: true},
+	{value: "medium", isFaulty: true},
+}
+
+func TestSchema_ParseType(t *testing.T) {
+	Convey("given a union with restricted member types", t, func() {
+		var s Schema
+		err := xml.Unmarshal([]byte(`<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:test" targetNamespace="urn:test">
+  <xsd:simpleType name="Code">
+    <xsd:restriction base="xsd:int"><xsd:maxInclusive value="99"/></xsd:restriction>
+  </xsd:simpleType>
+  <xsd:simpleType name="Size">
+    <xsd:union memberTypes="tns:Code">
+      <xsd:simpleType>
+        <xsd:restriction base="xsd:string">
+          <xsd:enumeration value="small"/>
+          <xsd:enumeration value="large"/>
+        </xsd:restriction>
+      </xsd:simpleType>
+    </xsd:union>
+  </xsd:simpleType>
+</xsd:schema>`), &s)
+		So(err, ShouldBeNil)
+
+		sr := SchemaMap{}
+		sr.Add(s)
+
+		for _, c := range parseTestCases {
+			Convey(fmt.Sprintf("test case '%s'", c.value), func() {
+				v, err := s.ParseType("Size", sr, c.value)
+				if c.isFaulty {
+					So(err, ShouldNotBeNil)
+					return
+				}
+
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, c.expectedResult)
+
+				formatted, err := s.FormatType("Size", sr, v)
+				So(err, ShouldBeNil)
+				So(formatted, ShouldEqual, c.value)
+			})
+		}
+	})
+}
+
+type patternTestCase struct {
+	pattern, value string
+	isFaulty       bool
+	isMalformed    bool
+}
+
+var patternTestCases = []patternTestCase{
+	{pattern: "[A-Z]{2}", value: "DE"},
+	{pattern: "[A-Z]{2}", value: "DEU", isFaulty: true},
+	{pattern: "^[0-9]+$", value: "^12$"},
+	{pattern: "^[0-9]+$", value: "12", isFaulty: true},
+	{pattern: "[^0-9]+", value: "abc"},
+	{pattern: `\i\c*`, value: "ns:name-1"},
+	{pattern: `\i\c*`, value: "1name", isFaulty: true},
+	{pattern: "a.c", value: "a\nc", isFaulty: true},
+	{pattern: "[a-z-[aeiou]]+", value: "xyz", isMalformed: true},
+	{pattern: `\p{IsBasicLatin}+`, value: "abc", isMalformed: true},
+}
+
+func TestSimpleTypeRestriction_Pattern(t *testing.T) {
+	Convey("given a restriction with a pattern", t, func() {
+		for _, c := range patternTestCases {
+			Convey(fmt.Sprintf("test case '%s' of '%s'", c.value, c.pattern), func() {
+				r := SimpleTypeRestriction{Patterns: []Facet{{Value: c.pattern}}}
+				_, err := r.validate("string", c.value)
+
+				var fe *FacetError
+				switch {
+				case c.isMalformed:
+					So(err, ShouldNotBeNil)
+					So(errors.As(err, &fe), ShouldBeFalse)
+				case c.isFaulty:
+					So(errors.As(err, &fe), ShouldBeTrue)
+					So(fe.Facet, ShouldEqual, "pattern")
+				default:
+					So(err, ShouldBeNil)
+				}
+			})
+		}
+	})
+}
@@ -2,7 +2,9 @@ package xsd
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 )
 
@@ -10,12 +12,28 @@ type SimpleType struct {
 	XMLName     xml.Name              `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
 	Name        string                `xml:"name,attr"`
 	Restriction SimpleTypeRestriction `xml:"restriction"`
+	List        *SimpleTypeList       `xml:"http://www.w3.org/2001/XMLSchema list"`
+	Union       *SimpleTypeUnion      `xml:"http://www.w3.org/2001/XMLSchema union"`
+	// doc is the included document declaring a global type merged into
+	// another schema, see Schema.Merge.
+	doc *Schema
 }
 
 type SimpleTypeRestriction struct {
-	XMLName      xml.Name      `xml:"http://www.w3.org/2001/XMLSchema restriction"`
-	Base         string        `xml:"base,attr"`
-	Enumerations []Enumeration `xml:"http://www.w3.org/2001/XMLSchema enumeration"`
+	XMLName        xml.Name      `xml:"http://www.w3.org/2001/XMLSchema restriction"`
+	Base           string        `xml:"base,attr"`
+	Enumerations   []Enumeration `xml:"http://www.w3.org/2001/XMLSchema enumeration"`
+	Patterns       []Facet       `xml:"http://www.w3.org/2001/XMLSchema pattern"`
+	Length         *Facet        `xml:"http://www.w3.org/2001/XMLSchema length"`
+	MinLength      *Facet        `xml:"http://www.w3.org/2001/XMLSchema minLength"`
+	MaxLength      *Facet        `xml:"http://www.w3.org/2001/XMLSchema maxLength"`
+	MinInclusive   *Facet        `xml:"http://www.w3.org/2001/XMLSchema minInclusive"`
+	MaxInclusive   *Facet        `xml:"http://www.w3.org/2001/XMLSchema maxInclusive"`
+	MinExclusive   *Facet        `xml:"http://www.w3.org/2001/XMLSchema minExclusive"`
+	MaxExclusive   *Facet        `xml:"http://www.w3.org/2001/XMLSchema maxExclusive"`
+	TotalDigits    *Facet        `xml:"http://www.w3.org/2001/XMLSchema totalDigits"`
+	FractionDigits *Facet        `xml:"http://www.w3.org/2001/XMLSchema fractionDigits"`
+	WhiteSpace     *Facet        `xml:"http://www.w3.org/2001/XMLSchema whiteSpace"`
 }
 
 type Enumeration struct {
@@ -23,21 +41,381 @@ type Enumeration struct {
 	Value   string   `xml:"value,attr"`
 }
 
-func (self *SimpleType) Encode(enc *xml.Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
-	name := self.Restriction.Base
-	parts := strings.Split(name, ":")
-	switch len(parts) {
-	case 2:
-		var schema Schemaer
-		schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
-		if err != nil {
+// SimpleTypeList is a white space separated list of values of its item
+// type, given either by name or inline.
+type SimpleTypeList struct {
+	XMLName    xml.Name    `xml:"http://www.w3.org/2001/XMLSchema list"`
+	ItemType   string      `xml:"itemType,attr"`
+	SimpleType *SimpleType `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+}
+
+// SimpleTypeUnion takes the values of any of its member types, which are
+// given by name or inline.
+type SimpleTypeUnion struct {
+	XMLName     xml.Name     `xml:"http://www.w3.org/2001/XMLSchema union"`
+	MemberTypes string       `xml:"memberTypes,attr"`
+	SimpleTypes []SimpleType `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+}
+
+// Encode writes the param at path. Values violating a facet of the type are
+// recorded as violations of enc. A slice given for a list type is a single
+// list, unless the element is repeated and takes one list per occurrence.
+func (self *SimpleType) Encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (err error) {
+	var primitive string
+	primitive, err = self.primitive(sr, ga)
+	if err != nil {
+		return
+	}
+
+	key := MakePath(path)
+	var v interface{}
+	var ok bool
+	if primitive == "list" && !enc.limit {
+		v, ok = enc.take(params, key)
+	} else {
+		v, ok = enc.value(params, key)
+	}
+	if !ok {
+		err = fmt.Errorf("did not find data '%s'", key)
+		return
+	}
+
+	var s string
+	s, err = self.Format(sr, ga, v)
+
+	var facetErr *FacetError
+	if errors.As(err, &facetErr) {
+		enc.Violate(key, "%s", facetErr)
+		err = nil
+		return
+	}
+
+	if err != nil {
+		return
+	}
+
+	err = enc.EncodeToken(xml.CharData(s))
+	return
+}
+
+// Format returns the lexical value of v, which has to satisfy the facets of
+// the type and of the types it is derived from.
+func (self *SimpleType) Format(sr SchemaRepository, ga GetAliaser, v interface{}) (s string, err error) {
+	switch {
+	case self.List != nil:
+		s, err = self.List.format(sr, ga, v)
+		return
+	case self.Union != nil:
+		s, err = self.Union.format(sr, ga, v)
+		return
+	}
+
+	var schema Schemaer
+	var name string
+	schema, name, err = self.base(sr, ga)
+	if err != nil {
+		return
+	}
+
+	s, err = schema.FormatType(name, sr, v)
+	if err != nil {
+		return
+	}
+
+	var primitive string
+	primitive, err = self.primitive(sr, ga)
+	if err != nil {
+		return
+	}
+
+	s, err = self.Restriction.validate(primitive, s)
+	return
+}
+
+func (self *SimpleType) base(sr SchemaRepository, ga GetAliaser) (schema Schemaer, name string, err error) {
+	parts := strings.Split(self.Restriction.Base, ":")
+	if len(parts) != 2 {
+		err = fmt.Errorf("invalid restriction format '%s'", self.Restriction.Base)
+		return
+	}
+
+	schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
+	name = parts[1]
+	return
+}
+
+// primitive returns the name of the builtin type the type derives from, or
+// 'list' and 'union' for types derived from a list or union.
+func (self *SimpleType) primitive(sr SchemaRepository, ga GetAliaser) (name string, err error) {
+	switch {
+	case self.List != nil:
+		name = "list"
+		return
+	case self.Union != nil:
+		name = "union"
+		return
+	}
+
+	var schema Schemaer
+	schema, name, err = self.base(sr, ga)
+	if err != nil {
+		return
+	}
+
+	s, ok := schema.(*Schema)
+	if !ok {
+		return
+	}
+
+	for _, smpl := range s.SimpleTypes {
+		if smpl.Name == name {
+			name, err = smpl.primitive(sr, s.scope(smpl.doc))
 			return
 		}
+	}
 
-		err = schema.EncodeType(parts[1], enc, sr, params, path...)
+	err = fmt.Errorf("did not find simple type '%s'", name)
+	return
+}
+
+// format joins the values of the items of v, which is a slice, a string
+// holding a white space separated list or a single item.
+func (self *SimpleTypeList) format(sr SchemaRepository, ga GetAliaser, v interface{}) (s string, err error) {
+	var items []interface{}
+	val := reflect.ValueOf(v)
+	switch {
+	case val.Kind() == reflect.String:
+		for _, item := range strings.Fields(val.String()) {
+			items = append(items, item)
+		}
+	case val.Kind() == reflect.Slice && val.Type().Elem().Kind() != reflect.Uint8:
+		for i := 0; i < val.Len(); i++ {
+			items = append(items, val.Index(i).Interface())
+		}
 	default:
-		err = fmt.Errorf("invalid restriction format '%s'", name)
+		items = []interface{}{v}
+	}
+
+	values := make([]string, len(items))
+	for i, item := range items {
+		if self.SimpleType != nil {
+			values[i], err = self.SimpleType.Format(sr, ga, item)
+		} else {
+			values[i], err = formatType(self.ItemType, sr, ga, item)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	s = strings.Join(values, " ")
+	return
+}
+
+// format returns the value of v for the first member type accepting it.
+func (self *SimpleTypeUnion) format(sr SchemaRepository, ga GetAliaser, v interface{}) (s string, err error) {
+	members := strings.Fields(self.MemberTypes)
+	for _, m := range members {
+		if s, err = formatType(m, sr, ga, v); err == nil {
+			return
+		}
+	}
+
+	for i := range self.SimpleTypes {
+		if s, err = self.SimpleTypes[i].Format(sr, ga, v); err == nil {
+			return
+		}
+	}
+
+	err = &FacetError{Facet: "memberTypes", Limit: fmt.Sprintf("%q", members), Value: fmt.Sprint(v)}
+	return
+}
+
+// formatType returns the value of v for the simple type qname like
+// 'xsd:int'.
+func formatType(qname string, sr SchemaRepository, ga GetAliaser, v interface{}) (s string, err error) {
+	parts := strings.Split(qname, ":")
+	if len(parts) != 2 {
+		err = fmt.Errorf("malformed type '%s'", qname)
+		return
+	}
+
+	var schema Schemaer
+	schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return
+	}
+
+	s, err = schema.FormatType(parts[1], sr, v)
+	return
+}
+
+// Parse returns the Go value of the lexical value s, see parseValue. Lists
+// give a []interface{} of their items and unions the value of the first
+// member type accepting s, which picks the same member as Format. Other
+// facets are not checked, so that responses are decoded as they are.
+func (self *SimpleType) Parse(sr SchemaRepository, ga GetAliaser, s string) (v interface{}, err error) {
+	switch {
+	case self.List != nil:
+		v, err = self.List.parse(sr, ga, s)
+		return
+	case self.Union != nil:
+		v, err = self.Union.parse(sr, ga, s)
+		return
+	}
+
+	var schema Schemaer
+	var name string
+	schema, name, err = self.base(sr, ga)
+	if err != nil {
+		return
+	}
+
+	v, err = schema.ParseType(name, sr, s)
+	return
+}
+
+func (self *SimpleTypeList) parse(sr SchemaRepository, ga GetAliaser, s string) (v interface{}, err error) {
+	items := []interface{}{}
+	for _, f := range strings.Fields(s) {
+		var item interface{}
+		if self.SimpleType != nil {
+			item, err = self.SimpleType.Parse(sr, ga, f)
+		} else {
+			item, err = parseType(self.ItemType, sr, ga, f)
+		}
+		if err != nil {
+			return
+		}
+
+		items = append(items, item)
+	}
+
+	v = items
+	return
+}
+
+// parse returns the value of s for the first member type accepting it,
+// which like in format has to satisfy the facets of the member.
+func (self *SimpleTypeUnion) parse(sr SchemaRepository, ga GetAliaser, s string) (v interface{}, err error) {
+	members := strings.Fields(self.MemberTypes)
+	for _, m := range members {
+		if v, err = parseType(m, sr, ga, s); err == nil {
+			if err = validateType(m, sr, ga, s); err == nil {
+				return
+			}
+		}
+	}
+
+	for i := range self.SimpleTypes {
+		if v, err = self.SimpleTypes[i].Parse(sr, ga, s); err == nil {
+			if err = self.SimpleTypes[i].validate(sr, ga, s); err == nil {
+				return
+			}
+		}
+	}
+
+	v = nil
+
+	err = fmt.Errorf("value '%s' matches none of the member types %q", s, members)
+	return
+}
+
+// parseType returns the value of s for the simple type qname like 'xsd:int'.
+func parseType(qname string, sr SchemaRepository, ga GetAliaser, s string) (v interface{}, err error) {
+	parts := strings.Split(qname, ":")
+	if len(parts) != 2 {
+		err = fmt.Errorf("malformed type '%s'", qname)
+		return
+	}
+
+	var schema Schemaer
+	schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return
 	}
 
+	v, err = schema.ParseType(parts[1], sr, s)
 	return
 }
+
+// validate checks the lexical value s against the facets of the type and
+// of the types it is derived from, like Format does for the values it
+// formats.
+func (self *SimpleType) validate(sr SchemaRepository, ga GetAliaser, s string) (err error) {
+	switch {
+	case self.List != nil:
+		for _, f := range strings.Fields(s) {
+			if self.List.SimpleType != nil {
+				err = self.List.SimpleType.validate(sr, ga, f)
+			} else {
+				err = validateType(self.List.ItemType, sr, ga, f)
+			}
+			if err != nil {
+				return
+			}
+		}
+		return
+	case self.Union != nil:
+		_, err = self.Union.parse(sr, ga, s)
+		return
+	}
+
+	var primitive string
+	primitive, err = self.primitive(sr, ga)
+	if err != nil {
+		return
+	}
+
+	s, err = self.Restriction.validate(primitive, s)
+	if err != nil {
+		return
+	}
+
+	err = validateType(self.Restriction.Base, sr, ga, s)
+	return
+}
+
+// validateType checks s against the facets of the simple type qname like
+// 'tns:Code'. Builtin types have no facets.
+func validateType(qname string, sr SchemaRepository, ga GetAliaser, s string) (err error) {
+	parts := strings.Split(qname, ":")
+	if len(parts) != 2 {
+		err = fmt.Errorf("malformed type '%s'", qname)
+		return
+	}
+
+	var schema Schemaer
+	schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return
+	}
+
+	sc, ok := schema.(*Schema)
+	if !ok {
+		return
+	}
+
+	for i := range sc.SimpleTypes {
+		if sc.SimpleTypes[i].Name == parts[1] {
+			err = sc.SimpleTypes[i].validate(sr, sc.scope(sc.SimpleTypes[i].doc), s)
+			return
+		}
+	}
+
+	err = fmt.Errorf("did not find simple type '%s'", qname)
+	return
+}
+
+// List holds the items of a list type, e.g. to decode '<ids>1 2 3</ids>' of
+// a response. Encoded as a param, it is a single list value.
+type List []string
+
+func (self List) MarshalText() ([]byte, error) {
+	return []byte(strings.Join(self, " ")), nil
+}
+
+func (self *List) UnmarshalText(text []byte) error {
+	*self = strings.Fields(string(text))
+	return nil
+}
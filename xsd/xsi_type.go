@@ -0,0 +1,139 @@
+package xsd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+const (
+	XMLSchemaNamespace         = "http://www.w3.org/2001/XMLSchema"
+	XMLSchemaInstanceNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// TypePath returns the param path selecting the type of the element at path
+// which is sent as xsi:type, e.g. "mutate/operations/@xsi:type". Its value
+// is either the local name of a type in the namespace of the declared type
+// or a name like '{namespace}local'.
+func TypePath(path []string) string {
+	return AttributePath(path, "xsi:type")
+}
+
+// instanceType returns the type the element at path is encoded with. This is
+// the type given by the xsi:type param, which must derive from the declared
+// type space/name and is added as xsi:type attribute to start, or the
+// declared type itself, which then must not be abstract.
+func instanceType(enc *Encoder, sr SchemaRepository, space, name string, start *xml.StartElement, params map[string]interface{}, path ...string) (schema Schemaer, typeName string, err error) {
+	schema, err = sr.GetSchema(space)
+	if err != nil {
+		return
+	}
+	typeName = name
+
+	key := TypePath(path)
+	v, ok := enc.value(params, key)
+	if !ok {
+		if s, ok := schema.(*Schema); ok {
+			if ct := s.complexType(name); ct != nil && ct.Abstract {
+				enc.Violate(MakePath(path), "type '%s' is abstract, set '%s' to a derived type", name, key)
+			}
+		}
+		return
+	}
+
+	derived, ok := v.(string)
+	if !ok {
+		err = fmt.Errorf("have %T, want string for '%s'", v, key)
+		return
+	}
+
+	derivedSpace := space
+	if strings.HasPrefix(derived, "{") {
+		if i := strings.Index(derived, "}"); i > 0 {
+			derivedSpace, derived = derived[1:i], derived[i+1:]
+		}
+	}
+
+	var s Schemaer
+	s, err = sr.GetSchema(derivedSpace)
+	if err != nil {
+		return
+	}
+
+	ds, ok := s.(*Schema)
+	var ct *ComplexType
+	if ok {
+		ct = ds.complexType(derived)
+	}
+
+	switch {
+	case ct == nil:
+		enc.Violate(key, "did not find complex type '%s' in namespace '%s'", derived, derivedSpace)
+		return
+	case !(space == XMLSchemaNamespace && name == "anyType") && !derivesFrom(sr, ds, derived, space, name):
+		enc.Violate(key, "type '%s' does not derive from '%s'", derived, name)
+		return
+	case ct.Abstract:
+		enc.Violate(key, "type '%s' is abstract", derived)
+		return
+	}
+
+	prefix := enc.Prefix(derivedSpace)
+	attr := xml.Attr{Name: xml.Name{Space: XMLSchemaInstanceNamespace, Local: "type"}, Value: prefix + ":" + derived}
+	if !enc.explicit {
+		// encoding/xml would make up a prefix for xsi itself
+		start.Attr = append(start.Attr,
+			xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: XMLSchemaInstanceNamespace},
+			xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: derivedSpace},
+		)
+		attr.Name = xml.Name{Local: "xsi:type"}
+	}
+	start.Attr = append(start.Attr, attr)
+
+	schema, typeName = ds, derived
+	return
+}
+
+// derivesFrom reports whether the complex type name of schema derives from
+// the type baseSpace/baseName by extension.
+func derivesFrom(sr SchemaRepository, schema *Schema, name, baseSpace, baseName string) bool {
+	// a chain this long is most likely a cycle
+	for i := 0; i < 64; i++ {
+		ct := schema.complexType(name)
+		if ct == nil {
+			return false
+		}
+
+		ext := ct.extension()
+		if ext == nil {
+			ext = ct.simpleExtension()
+		}
+		if ext == nil {
+			return false
+		}
+
+		parts := strings.Split(ext.Base, ":")
+		if len(parts) != 2 {
+			return false
+		}
+
+		space := schema.scope(ct.doc).GetAlias(parts[0])
+		if space == baseSpace && parts[1] == baseName {
+			return true
+		}
+
+		s, err := sr.GetSchema(space)
+		if err != nil {
+			return false
+		}
+
+		var ok bool
+		schema, ok = s.(*Schema)
+		if !ok {
+			return false
+		}
+		name = parts[1]
+	}
+
+	return false
+}